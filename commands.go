@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"./json"
+)
+
+func runFormat(flags *flag.FlagSet, args []string) int {
+	return runPrint(flags, args, false)
+}
+
+func runHighlight(flags *flag.FlagSet, args []string) int {
+	return runPrint(flags, args, true)
+}
+
+// runPrint prints every document, highlighted as it is if highlight is set
+func runPrint(flags *flag.FlagSet, args []string, highlight bool) int {
+	r := addRenderFlags(flags, "html")
+	files := addFileFlags(flags)
+	var rewrite rewriteFlags
+	if !highlight {
+		flags.BoolVar(&r.highlight, "highlight", false, "highlight the input exactly as it is, rather than laying it out again")
+		flags.BoolVar(&rewrite.write, "w", false, "write the text format back to the files, rather than printing them")
+		flags.BoolVar(&rewrite.check, "check", false, "list the files that the text format would change, exiting with 1 if there are any")
+		flags.BoolVar(&rewrite.diff, "diff", false, "show how the text format would change the files, as a unified diff")
+	}
+	if status, ok := parseFlags(flags, args); !ok {
+		return status
+	}
+	r.highlight = r.highlight || highlight
+	if rewrite.any() {
+		if r.highlight {
+			fmt.Fprintln(os.Stderr, "-w, -check and -diff can not be used with -highlight")
+			return exitTrouble
+		}
+		r.format = "text"
+	}
+	if err := r.setUp(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitTrouble
+	}
+	paths, walked := files.paths(flags.Args())
+	if rewrite.any() {
+		return runRewrite(r, paths, files.jobs, walked, rewrite)
+	}
+
+	// Each problem gets a status of its own, and the worst one is exited with
+	printed := make([]*section, len(paths))
+	outcomes := process(paths, files.jobs, func(i int, path string, o *outcome) {
+		name, source, err := readInput(path)
+		if err != nil {
+			fmt.Fprintln(&o.problems, err)
+			o.status = exitTrouble
+			return
+		}
+		section, valid, err := r.render(&o.problems, name, source)
+		if err != nil {
+			fmt.Fprintf(&o.problems, "%s: %v\n", name, err)
+			o.status = exitTrouble
+			return
+		}
+		if !valid {
+			o.status = exitInvalid
+			if r.options.Format != json.FormatHTML {
+				return
+			}
+		}
+		printed[i] = &section
+	})
+	var sections []section
+	for _, section := range printed {
+		if section != nil {
+			sections = append(sections, *section)
+		}
+	}
+	if err := r.writeOutput(sections); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitTrouble
+	}
+	return summarize(outcomes, walked, false)
+}
+
+func runValidate(flags *flag.FlagSet, args []string) int {
+	var format, output string
+	flags.StringVar(&format, "format", "plain", "how to write the problems found: plain (file:line:column: message), json or sarif")
+	flags.StringVar(&output, "o", "", "write to this file instead of standard output")
+	flags.StringVar(&output, "output", "", "same as -o")
+	files := addFileFlags(flags)
+	if status, ok := parseFlags(flags, args); !ok {
+		return status
+	}
+	if format != "plain" && format != "json" && format != "sarif" {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", format)
+		return exitTrouble
+	}
+
+	paths, walked := files.paths(flags.Args())
+	found := make([]*fileDiagnostics, len(paths))
+	outcomes := process(paths, files.jobs, func(i int, path string, o *outcome) {
+		name, source, err := readInput(path)
+		if err != nil {
+			fmt.Fprintln(&o.problems, err)
+			o.status = exitTrouble
+			return
+		}
+		diagnostics := json.Validate(bytes.NewReader(source))
+		if len(diagnostics) > 0 {
+			o.status = exitInvalid
+		}
+		found[i] = &fileDiagnostics{name, diagnostics}
+	})
+	var diagnosed []fileDiagnostics
+	for _, file := range found {
+		if file != nil {
+			diagnosed = append(diagnosed, *file)
+		}
+	}
+
+	w, err := openOutput(output)
+	if err == nil {
+		err = writeDiagnostics(w, format, diagnosed)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitTrouble
+	}
+	return summarize(outcomes, walked, false)
+}
+
+func runQuery(flags *flag.FlagSet, args []string) int {
+	// Queries are mostly run in terminals, where ansi falls back to plain text
+	// if it has to
+	r := addRenderFlags(flags, "ansi")
+	if status, ok := parseFlags(flags, args); !ok {
+		return status
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return exitTrouble
+	}
+	path, err := json.ParsePointer(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitTrouble
+	}
+	if err := r.setUp(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitTrouble
+	}
+
+	status := 0
+	var sections []section
+	for _, input := range expandArgs(flags.Args()[1:]) {
+		name, source, err := readInput(input)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = exitTrouble
+			continue
+		}
+		tree, errs := parseDocument(os.Stderr, name, source)
+		if len(errs) > 0 {
+			if status < exitInvalid {
+				status = exitInvalid
+			}
+			continue
+		}
+		node, ok := json.Find(tree, path)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s: nothing at %q\n", name, path.String())
+			if status < exitInvalid {
+				status = exitInvalid
+			}
+			continue
+		}
+		section, err := r.renderNode(name, node)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			status = exitTrouble
+			continue
+		}
+		sections = append(sections, section)
+	}
+	if err := r.writeOutput(sections); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitTrouble
+	}
+	return status
+}
+
+// describeChange writes a change as a line such as "~ /a/0: 1 -> 2"
+func describeChange(change json.Change) (string, error) {
+	var values []string
+	for _, node := range []json.Node{change.Old, change.New} {
+		if node != nil {
+			value, err := json.Compact(node)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, value)
+		}
+	}
+	switch change.Kind {
+	case json.Added:
+		return fmt.Sprintf("+ %s: %s", change.Path, values[0]), nil
+	case json.Removed:
+		return fmt.Sprintf("- %s: %s", change.Path, values[0]), nil
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", change.Path, values[0], values[1]), nil
+}
+
+func runDiff(flags *flag.FlagSet, args []string) int {
+	var output string
+	flags.StringVar(&output, "o", "", "write to this file instead of standard output")
+	flags.StringVar(&output, "output", "", "same as -o")
+	if status, ok := parseFlags(flags, args); !ok {
+		return status
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitTrouble
+	}
+
+	var trees []json.Node
+	for _, path := range flags.Args() {
+		name, source, err := readInput(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitTrouble
+		}
+		tree, errs := parseDocument(os.Stderr, name, source)
+		if len(errs) > 0 {
+			return exitInvalid
+		}
+		trees = append(trees, tree)
+	}
+
+	var buffer bytes.Buffer
+	changes := json.Diff(trees[0], trees[1])
+	for _, change := range changes {
+		line, err := describeChange(change)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitTrouble
+		}
+		buffer.WriteString(line)
+		buffer.WriteString("\n")
+	}
+	w, err := openOutput(output)
+	if err == nil {
+		_, err = buffer.WriteTo(w)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitTrouble
+	}
+	// Like diff(1), differences are a status of their own
+	if len(changes) > 0 {
+		return exitInvalid
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunQuery(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"a.json":   `{"a": [1, {"b": true}], "c": null}`,
+		"bad.json": `{"a": }`,
+	})
+	a, bad := filepath.Join(root, "a.json"), filepath.Join(root, "bad.json")
+
+	var status int
+	stdout, _ := capture(t, func() { status = run([]string{"query", "/a/1", a, "-format", "text"}) })
+	assert(status == 0 && stdout == "{\n    \"b\": true\n}\n", fmt.Sprintf("Wrong value %q (%d)", stdout, status))
+
+	stdout, stderr := capture(t, func() { status = run([]string{"query", "-format", "text", "/a/5", a}) })
+	assert(status == exitInvalid && stdout == "", fmt.Sprintf("Should have found nothing, but got %q (%d)", stdout, status))
+	assert(strings.Contains(stderr, `nothing at "/a/5"`), fmt.Sprintf("Should have reported the missing value, not %q", stderr))
+
+	_, _ = capture(t, func() { status = run([]string{"query", "/a", bad}) })
+	assert(status == exitInvalid, fmt.Sprintf("Should have exited with %d for an invalid document, not %d", exitInvalid, status))
+
+	_, _ = capture(t, func() { status = run([]string{"query", "/a", filepath.Join(root, "missing.json")}) })
+	assert(status == exitTrouble, fmt.Sprintf("Should have exited with %d for a missing file, not %d", exitTrouble, status))
+
+	_, _ = capture(t, func() { status = run([]string{"query", "a", a}) })
+	assert(status == exitTrouble, "Should have rejected a pointer without a leading slash")
+}
+
+func TestRunDiff(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"old.json": `{"a": 1, "b": [1, 2]}`,
+		"new.json": `{"a": 2, "b": [1], "c": "x"}`,
+		"bad.json": `[1`,
+	})
+	before, after, bad := filepath.Join(root, "old.json"), filepath.Join(root, "new.json"), filepath.Join(root, "bad.json")
+
+	var status int
+	stdout, _ := capture(t, func() { status = run([]string{"diff", before, after}) })
+	expected := "~ /a: 1 -> 2\n- /b/1: 2\n+ /c: \"x\"\n"
+	assert(status == exitInvalid && stdout == expected, fmt.Sprintf("Expected\n%s\nbut got\n%s(%d)", expected, stdout, status))
+
+	// Flags can follow the documents
+	output := filepath.Join(root, "out.txt")
+	stdout, _ = capture(t, func() { status = run([]string{"diff", before, before, "-o", output}) })
+	assert(status == 0 && stdout == "", fmt.Sprintf("Should have found no differences, but got %q (%d)", stdout, status))
+
+	_, stderr := capture(t, func() { status = run([]string{"diff", before, bad}) })
+	assert(status == exitInvalid && stderr != "", fmt.Sprintf("Should have exited with %d for an invalid document, not %d", exitInvalid, status))
+
+	_, _ = capture(t, func() { status = run([]string{"diff", before, filepath.Join(root, "missing.json")}) })
+	assert(status == exitTrouble, fmt.Sprintf("Should have exited with %d for a missing file, not %d", exitTrouble, status))
+
+	_, _ = capture(t, func() { status = run([]string{"diff", before}) })
+	assert(status == exitTrouble, "Should have needed two documents")
+}
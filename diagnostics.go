@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+
+	"./json"
+)
+
+// fileDiagnostics are the diagnostics of one file
+type fileDiagnostics struct {
+	name        string
+	diagnostics []json.Diagnostic
+}
+
+// writeDiagnostics writes diagnostics in one of the formats of validate:
+// plain, json or sarif.
+func writeDiagnostics(w io.Writer, format string, files []fileDiagnostics) error {
+	var buffer bytes.Buffer
+	switch format {
+	case "plain":
+		for _, file := range files {
+			for _, diagnostic := range file.diagnostics {
+				fmt.Fprintf(&buffer, "%s:%d:%d: %s\n", file.name, diagnostic.Position.Line, diagnostic.Position.Column, diagnostic.Message)
+			}
+		}
+	case "json":
+		data, err := stdjson.MarshalIndent(diagnosticsJSON(files), "", "  ")
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+		buffer.WriteString("\n")
+	case "sarif":
+		data, err := stdjson.MarshalIndent(diagnosticsSARIF(files), "", "  ")
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+		buffer.WriteString("\n")
+	default:
+		return fmt.Errorf("unknown diagnostics format %q", format)
+	}
+	_, err := buffer.WriteTo(w)
+	return err
+}
+
+type diagnosticJSON struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	Offset    int    `json:"offset"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	EndOffset int    `json:"endOffset"`
+	Message   string `json:"message"`
+}
+
+// diagnosticsJSON lists the diagnostics of every file in a single array.
+// Lines and columns count from 1, and columns in characters. Offsets count
+// bytes from 0.
+func diagnosticsJSON(files []fileDiagnostics) []diagnosticJSON {
+	list := []diagnosticJSON{}
+	for _, file := range files {
+		for _, d := range file.diagnostics {
+			list = append(list, diagnosticJSON{
+				File:      file.name,
+				Line:      d.Position.Line,
+				Column:    d.Position.Column,
+				Offset:    d.Position.Offset,
+				EndLine:   d.End.Line,
+				EndColumn: d.End.Column,
+				EndOffset: d.End.Offset,
+				Message:   d.Message,
+			})
+		}
+	}
+	return list
+}
+
+// The parts of SARIF 2.1.0 that validate needs
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool       sarifTool     `json:"tool"`
+		ColumnKind string        `json:"columnKind"`
+		Results    []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name    string      `json:"name"`
+		Version string      `json:"version"`
+		Rules   []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           sarifRegion           `json:"region"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn"`
+		EndLine     int `json:"endLine"`
+		EndColumn   int `json:"endColumn"`
+	}
+)
+
+// artifactURI turns a file name into the relative URI that SARIF locates files
+// by, escaping spaces, "%" and the like
+func artifactURI(name string) string {
+	uri := url.URL{Path: filepath.ToSlash(name)}
+	return uri.String()
+}
+
+// syntaxRule is the SARIF rule that every diagnostic breaks
+const syntaxRule = "json-syntax"
+
+// diagnosticsSARIF describes the diagnostics as a SARIF log, for code scanning
+// tools. Columns count characters, rather than the UTF-16 code units that
+// SARIF counts by default.
+func diagnosticsSARIF(files []fileDiagnostics) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{sarifDriver{
+			Name:    programName(),
+			Version: version,
+			Rules:   []sarifRule{{syntaxRule, sarifMessage{"The document is not valid JSON"}}},
+		}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+	for _, file := range files {
+		for _, d := range file.diagnostics {
+			run.Results = append(run.Results, sarifResult{
+				RuleID:  syntaxRule,
+				Level:   "error",
+				Message: sarifMessage{d.Message},
+				Locations: []sarifLocation{{sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{artifactURI(file.name)},
+					Region:           sarifRegion{d.Position.Line, d.Position.Column, d.End.Line, d.End.Column},
+				}}},
+			})
+		}
+	}
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
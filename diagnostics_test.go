@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"./json"
+)
+
+// diagnose validates source as the file name
+func diagnose(name, source string) []fileDiagnostics {
+	return []fileDiagnostics{{name, json.Validate(strings.NewReader(source))}}
+}
+
+func TestDiagnosticsJSON(t *testing.T) {
+	// The "é" takes one column but two bytes
+	files := diagnose("a.json", "{\n  \"é\": 1 2}")
+	var buffer bytes.Buffer
+	assert(writeDiagnostics(&buffer, "json", files) == nil, "Should have written the diagnostics")
+	var list []diagnosticJSON
+	assert(stdjson.Unmarshal(buffer.Bytes(), &list) == nil, fmt.Sprintf("Should have written JSON, not %s", buffer.String()))
+	assert(len(list) == 1, fmt.Sprintf("Should have found 1 problem, but found %d", len(list)))
+	d := list[0]
+	assert(d.File == "a.json" && strings.Contains(d.Message, "number 2"), fmt.Sprintf("Wrong diagnostic %+v", d))
+	assert(d.Line == 2 && d.Column == 10 && d.Offset == 12, fmt.Sprintf("Wrong start %d:%d (%d)", d.Line, d.Column, d.Offset))
+	assert(d.EndLine == 2 && d.EndColumn == 11 && d.EndOffset == 13, fmt.Sprintf("Wrong end %d:%d (%d)", d.EndLine, d.EndColumn, d.EndOffset))
+
+	buffer.Reset()
+	assert(writeDiagnostics(&buffer, "json", diagnose("b.json", "[]")) == nil, "Should have written the diagnostics")
+	assert(buffer.String() == "[]\n", fmt.Sprintf("Should have written an empty list, not %q", buffer.String()))
+
+	buffer.Reset()
+	assert(writeDiagnostics(&buffer, "plain", files) == nil, "Should have written the diagnostics")
+	assert(strings.HasPrefix(buffer.String(), "a.json:2:10: "), fmt.Sprintf("Wrong plain diagnostic %q", buffer.String()))
+
+	assert(writeDiagnostics(&buffer, "xml", files) != nil, "Should have rejected an unknown format")
+}
+
+func TestDiagnosticsSARIF(t *testing.T) {
+	files := diagnose("my dir/100% sure.json", "{\n  \"é\": 1 2}")
+	var buffer bytes.Buffer
+	assert(writeDiagnostics(&buffer, "sarif", files) == nil, "Should have written the diagnostics")
+	var log sarifLog
+	assert(stdjson.Unmarshal(buffer.Bytes(), &log) == nil, fmt.Sprintf("Should have written JSON, not %s", buffer.String()))
+	assert(log.Version == "2.1.0" && len(log.Runs) == 1, "Should have written a single SARIF 2.1.0 run")
+	run := log.Runs[0]
+	assert(run.ColumnKind == "unicodeCodePoints", fmt.Sprintf("Columns should have counted characters, not %s", run.ColumnKind))
+	assert(len(run.Results) == 1, fmt.Sprintf("Should have found 1 problem, but found %d", len(run.Results)))
+	result := run.Results[0]
+	assert(result.RuleID == syntaxRule && result.Level == "error", fmt.Sprintf("Wrong result %+v", result))
+	location := result.Locations[0].PhysicalLocation
+	assert(location.ArtifactLocation.URI == "my%20dir/100%25%20sure.json", fmt.Sprintf("Wrong URI %s", location.ArtifactLocation.URI))
+	assert(location.Region == sarifRegion{2, 10, 2, 11}, fmt.Sprintf("Wrong region %+v", location.Region))
+
+	assert(artifactURI("a:b.json") == "./a:b.json", fmt.Sprintf("Should not have made a scheme of a, but got %s", artifactURI("a:b.json")))
+}
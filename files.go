@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// patterns is a flag that can be given several times
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, " ")
+}
+
+func (p *patterns) Set(pattern string) error {
+	*p = append(*p, pattern)
+	return nil
+}
+
+// fileFlags are the flags of the commands that can work on whole directories
+type fileFlags struct {
+	include patterns
+	exclude patterns
+	jobs    int
+}
+
+func addFileFlags(flags *flag.FlagSet) *fileFlags {
+	f := &fileFlags{}
+	flags.Var(&f.include, "include", "read the files of directories whose names match this glob pattern; can be given several times (default *.json)")
+	flags.Var(&f.exclude, "exclude", "skip the files and directories that this .gitignore-style pattern matches; can be given several times")
+	flags.IntVar(&f.jobs, "jobs", runtime.NumCPU(), "how many files to work on at once")
+	return f
+}
+
+// paths expands the arguments like expandArgs, and walks the directories among
+// them. It returns whether there were any directories.
+func (f *fileFlags) paths(args []string) ([]string, bool) {
+	var paths []string
+	walked := false
+	for _, arg := range expandArgs(args) {
+		if info, err := os.Stat(arg); err == nil && info.IsDir() && arg != "-" {
+			paths = append(paths, f.walk(arg)...)
+			walked = true
+			continue
+		}
+		paths = append(paths, arg)
+	}
+	return paths, walked
+}
+
+// walk returns the files under root whose names match the include patterns, in
+// lexical order. Files and directories that the exclude patterns match are
+// skipped, as are those that the .gitignore files along the way match.
+func (f *fileFlags) walk(root string) []string {
+	include := f.include
+	if len(include) == 0 {
+		include = patterns{"*.json"}
+	}
+	var excludes, rules []ignoreRule
+	for _, line := range f.exclude {
+		if rule, ok := parseIgnoreRule(root, line); ok {
+			excludes = append(excludes, rule)
+		}
+	}
+
+	var paths []string
+	filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Reading it reports the problem
+			paths = append(paths, name)
+			return nil
+		}
+		// As with git, patterns from the command line come after .gitignore files
+		if name != root && ignored(name, entry.IsDir(), rules, excludes) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" && name != root {
+				return filepath.SkipDir
+			}
+			rules = append(rules, readIgnoreFile(name)...)
+			return nil
+		}
+		for _, pattern := range include {
+			if matched, _ := filepath.Match(pattern, entry.Name()); matched {
+				paths = append(paths, name)
+				break
+			}
+		}
+		return nil
+	})
+	return paths
+}
+
+// ignoreRule is a line of a .gitignore file, or an exclude pattern
+type ignoreRule struct {
+	// base is the directory that the pattern is relative to
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// parseIgnoreRule parses a pattern like those of .gitignore files, relative to
+// base. It returns false for blank lines and comments.
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// A pattern with no slash but at the end matches names at any depth, and
+	// one with a slash matches paths from base
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+// matches returns whether the rule matches the file or directory at name
+func (rule ignoreRule) matches(name string, dir bool) bool {
+	if rule.dirOnly && !dir {
+		return false
+	}
+	relative, err := filepath.Rel(rule.base, name)
+	if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return matchSegments(rule.segments, strings.Split(filepath.ToSlash(relative), "/"))
+}
+
+// matchSegments matches a path against a pattern, a segment at a time, with
+// "**" standing for any number of segments.
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	matched, _ := path.Match(pattern[0], name[0])
+	return matched && matchSegments(pattern[1:], name[1:])
+}
+
+// ignored returns whether the lists of rules exclude name. As in .gitignore
+// files, the last rule that matches wins.
+func ignored(name string, dir bool, lists ...[]ignoreRule) bool {
+	ignore := false
+	for _, rules := range lists {
+		for _, rule := range rules {
+			if rule.matches(name, dir) {
+				ignore = !rule.negate
+			}
+		}
+	}
+	return ignore
+}
+
+// readIgnoreFile reads the rules of the .gitignore file in dir, if it has one
+func readIgnoreFile(dir string) []ignoreRule {
+	source, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(source), "\n") {
+		if rule, ok := parseIgnoreRule(dir, line); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// outcome is what came of working on a file
+type outcome struct {
+	status  int
+	changed bool
+	// problems are kept for standard error until the files before are done
+	problems bytes.Buffer
+}
+
+// process calls do with every path, on as many as jobs paths at once, and
+// writes the problems of each to standard error in the order of the paths.
+func process(paths []string, jobs int, do func(i int, path string, o *outcome)) []outcome {
+	outcomes := make([]outcome, len(paths))
+	indices := make(chan int)
+	var wait sync.WaitGroup
+	for worker := 0; worker < jobs || worker == 0; worker++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range indices {
+				do(i, paths[i], &outcomes[i])
+			}
+		}()
+	}
+	for i := range paths {
+		indices <- i
+	}
+	close(indices)
+	wait.Wait()
+
+	for i := range outcomes {
+		outcomes[i].problems.WriteTo(os.Stderr)
+	}
+	return outcomes
+}
+
+// summarize returns the worst status of the outcomes. For runs over
+// directories, it also writes how many files were checked, changed and failed
+// to standard error, leaving out changed if nothing could change.
+func summarize(outcomes []outcome, walked, changing bool) int {
+	status, changed, failed := 0, 0, 0
+	for _, o := range outcomes {
+		if o.status > status {
+			status = o.status
+		}
+		if o.changed {
+			changed++
+		} else if o.status != 0 {
+			failed++
+		}
+	}
+	if walked {
+		if changing {
+			fmt.Fprintf(os.Stderr, "%d files checked, %d changed, %d failed\n", len(outcomes), changed, failed)
+		} else {
+			fmt.Fprintf(os.Stderr, "%d files checked, %d failed\n", len(outcomes), failed)
+		}
+	}
+	return status
+}
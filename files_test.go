@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeTree creates the files in a temporary directory, and returns it
+func makeTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		assert(os.MkdirAll(filepath.Dir(path), 0755) == nil, "Should have made "+filepath.Dir(path))
+		assert(os.WriteFile(path, []byte(contents), 0644) == nil, "Should have written "+path)
+	}
+	return root
+}
+
+// walked returns the paths that f finds under root, relative to it
+func walked(f *fileFlags, root string) string {
+	var paths []string
+	for _, path := range f.walk(root) {
+		relative, err := filepath.Rel(root, path)
+		assert(err == nil, fmt.Sprintf("Should have walked under %s, not %s", root, path))
+		paths = append(paths, filepath.ToSlash(relative))
+	}
+	return strings.Join(paths, " ")
+}
+
+func TestWalk(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		".gitignore": "# generated\n" +
+			"gen/*\n" +
+			"!gen/keep.json\n" +
+			"/anchored.json\n" +
+			"build/\n" +
+			"ignored/\n" +
+			"!ignored/back.json\n" +
+			"**/deep/*.json\n",
+		"a.json":                "",
+		"anchored.json":         "",
+		"notes.txt":             "",
+		"map.geojson":           "",
+		"gen/out.json":          "",
+		"gen/keep.json":         "",
+		"build/b.json":          "",
+		"ignored/back.json":     "",
+		"sub/anchored.json":     "",
+		"sub/build":             "",
+		"sub/deep/d.json":       "",
+		"sub/x/deep/d.json":     "",
+		"sub/.gitignore":        "local.json\n",
+		"sub/local.json":        "",
+		"other/local.json":      "",
+		".git/config.json":      "",
+		"node_modules/m.json":   "",
+		"node_modules/x/n.json": "",
+	})
+
+	f := &fileFlags{}
+	expected := "a.json gen/keep.json node_modules/m.json node_modules/x/n.json other/local.json sub/anchored.json"
+	assert(walked(f, root) == expected, fmt.Sprintf("Expected %s, but walked %s", expected, walked(f, root)))
+
+	f = &fileFlags{include: patterns{"*.json", "*.geojson", "build"}, exclude: patterns{"node_modules/", "/other"}}
+	expected = "a.json gen/keep.json map.geojson sub/anchored.json sub/build"
+	assert(walked(f, root) == expected, fmt.Sprintf("Expected %s, but walked %s", expected, walked(f, root)))
+
+	// The last pattern that matches wins
+	f = &fileFlags{exclude: patterns{"*.json", "!a.json"}}
+	assert(walked(f, root) == "a.json", fmt.Sprintf("Should only have walked a.json, not %s", walked(f, root)))
+}
+
+func TestIgnoreRule(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		dir, matches  bool
+	}{
+		{"*.json", "a.json", false, true},
+		{"*.json", "x/y/a.json", false, true},
+		{"/a.json", "a.json", false, true},
+		{"/a.json", "x/a.json", false, false},
+		{"x/a.json", "x/a.json", false, true},
+		{"x/a.json", "y/x/a.json", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"a/**/b", "a/b", true, true},
+		{"a/**/b", "a/x/y/b", true, true},
+		{"**/b", "x/b", false, true},
+		{`\#a`, "#a", false, true},
+		{"#a", "#a", false, false},
+		{"", "a", false, false},
+	}
+	for _, test := range tests {
+		rule, ok := parseIgnoreRule("root", test.pattern)
+		matches := ok && rule.matches(filepath.Join("root", filepath.FromSlash(test.name)), test.dir)
+		assert(matches == test.matches, fmt.Sprintf("Expected %q matching %s to be %v", test.pattern, test.name, test.matches))
+	}
+}
+
+func TestPaths(t *testing.T) {
+	root := makeTree(t, map[string]string{"d/a.json": "", "d/b.txt": "", "c.txt": ""})
+	f := &fileFlags{}
+	paths, walkedDirectory := f.paths([]string{filepath.Join(root, "c.txt"), filepath.Join(root, "d")})
+	assert(walkedDirectory, "Should have walked the directory")
+	expected := []string{filepath.Join(root, "c.txt"), filepath.Join(root, "d", "a.json")}
+	assert(strings.Join(paths, " ") == strings.Join(expected, " "), fmt.Sprintf("Expected %v, but got %v", expected, paths))
+
+	paths, walkedDirectory = f.paths(nil)
+	assert(!walkedDirectory && len(paths) == 1 && paths[0] == "-", fmt.Sprintf("Should have read standard input, not %v", paths))
+}
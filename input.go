@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stdinName is what standard input is called in messages and headings
+const stdinName = "<stdin>"
+
+// expandArgs turns the arguments into the paths to read, in order. Glob
+// patterns are expanded, for shells that leave them be, and a pattern that
+// matches nothing is kept as it is so that reading it reports the problem.
+// "-", or no arguments at all, stands for standard input.
+func expandArgs(args []string) []string {
+	if len(args) == 0 {
+		return []string{"-"}
+	}
+	var paths []string
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "*?[") {
+			if matches, err := filepath.Glob(arg); err == nil && len(matches) > 0 {
+				paths = append(paths, matches...)
+				continue
+			}
+		}
+		paths = append(paths, arg)
+	}
+	return paths
+}
+
+// readInput reads the document at path, "-" being standard input, and returns
+// it along with the name to report it by.
+func readInput(path string) (string, []byte, error) {
+	if path == "-" {
+		source, err := io.ReadAll(os.Stdin)
+		return stdinName, source, err
+	}
+	source, err := os.ReadFile(path)
+	return path, source, err
+}
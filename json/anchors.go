@@ -0,0 +1,30 @@
+package json
+
+// AnchorStylesheet is the CSS that the line numbers and anchors of a printer
+// with LineNumbers or PathAnchors set need, on top of the colours.
+const AnchorStylesheet = `.j-line::before {
+  content: attr(data-line);
+  display: inline-block;
+  min-width: 4ch;
+  margin-right: 2ch;
+  text-align: right;
+  opacity: 0.5;
+  user-select: none;
+}
+.j-member { cursor: copy; }
+.j-member:target { outline: 1px dashed; }
+`
+
+// AnchorScript copies the JSON Pointer of the innermost property or element
+// that is clicked, and links the page to it.
+const AnchorScript = `document.addEventListener('click', function (event) {
+  var member = event.target.closest && event.target.closest('.j-member');
+  if (!member || event.target.closest('.j-toggle')) {
+    return;
+  }
+  if (navigator.clipboard) {
+    navigator.clipboard.writeText(member.id);
+  }
+  history.replaceState(null, '', '#' + encodeURI(member.id).replace(/#/g, '%23'));
+});
+`
@@ -0,0 +1,115 @@
+package json
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ColorMode is the range of colours that an ANSI terminal can show
+type ColorMode int
+
+const (
+	// ColorNone writes no escape sequences at all
+	ColorNone ColorMode = iota
+	// Color16 uses the 16 standard terminal colours
+	Color16
+	// Color256 uses the xterm 256 colour palette
+	Color256
+	// ColorTrueColor uses 24-bit colours
+	ColorTrueColor
+)
+
+const (
+	ansiReset     = "\x1b[0m"
+	ansiUnderline = "\x1b[4m"
+)
+
+// parseHexColor splits a colour such as "2aa198" into its red, green and blue
+func parseHexColor(color string) (r, g, b int) {
+	value, err := strconv.ParseUint(color, 16, 32)
+	if err != nil || len(color) != 6 {
+		return 0, 0, 0
+	}
+	return int(value >> 16 & 0xff), int(value >> 8 & 0xff), int(value & 0xff)
+}
+
+func square(n int) int {
+	return n * n
+}
+
+// The 16 standard colours, as xterm shows them, in the order of their codes
+var ansi16Colors = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// nearest16 returns the index of the standard colour closest to r, g, b
+func nearest16(r, g, b int) int {
+	best, bestDistance := 0, -1
+	for i, c := range ansi16Colors {
+		distance := square(r-c[0]) + square(g-c[1]) + square(b-c[2])
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
+
+// The levels of each channel in the 6x6x6 colour cube of the 256 colour palette
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+func nearestCubeLevel(n int) int {
+	best := 0
+	for i, level := range cubeLevels {
+		if square(n-level) < square(n-cubeLevels[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// nearest256 returns the index of the colour of the 256 colour palette closest
+// to r, g, b, picking from the colour cube and the grey ramp.
+func nearest256(r, g, b int) int {
+	ri, gi, bi := nearestCubeLevel(r), nearestCubeLevel(g), nearestCubeLevel(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDistance := square(r-cubeLevels[ri]) + square(g-cubeLevels[gi]) + square(b-cubeLevels[bi])
+
+	// The grey ramp goes from 8 to 238, in steps of 10
+	grey := (r + g + b) / 3
+	greyIndex := (grey - 3) / 10
+	if greyIndex < 0 {
+		greyIndex = 0
+	} else if greyIndex > 23 {
+		greyIndex = 23
+	}
+	level := 8 + 10*greyIndex
+	greyDistance := square(r-level) + square(g-level) + square(b-level)
+
+	if greyDistance < cubeDistance {
+		return 232 + greyIndex
+	}
+	return cube
+}
+
+// ansiColor returns the escape sequence that sets the foreground to color, a
+// hex colour such as "2aa198".
+func ansiColor(color string, mode ColorMode) string {
+	r, g, b := parseHexColor(color)
+	switch mode {
+	case ColorTrueColor:
+		return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", r, g, b)
+	case Color256:
+		return fmt.Sprintf("\x1b[38;5;%dm", nearest256(r, g, b))
+	case Color16:
+		// The bright colours have codes of their own, rather than being bold
+		i := nearest16(r, g, b)
+		if i >= 8 {
+			return fmt.Sprintf("\x1b[%dm", 90+i-8)
+		}
+		return fmt.Sprintf("\x1b[%dm", 30+i)
+	}
+	return ""
+}
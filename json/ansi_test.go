@@ -0,0 +1,30 @@
+package json
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestANSIColor(t *testing.T) {
+	assert(ansiColor("2aa198", ColorTrueColor) == "\x1b[38;2;42;161;152m", "Should have written the exact colour")
+	assert(ansiColor("ff0000", Color256) == "\x1b[38;5;196m", "Pure red should have been in the colour cube")
+	assert(ansiColor("808080", Color256) == "\x1b[38;5;244m", "Grey should have been on the grey ramp")
+	assert(ansiColor("000000", Color256) == "\x1b[38;5;16m", "Black should have been in the colour cube")
+	assert(ansiColor("cd0000", Color16) == "\x1b[31m", "Red should have been red")
+	assert(ansiColor("ffffff", Color16) == "\x1b[97m", "White should have been bright white")
+	assert(ansiColor("2aa198", ColorNone) == "", "Should not have written anything without colours")
+
+	options := DefaultPrinterOptions()
+	options.Format = FormatANSI
+	options.Colors = ColorTrueColor
+	output := printString("[true, null]", options)
+	expected := "\x1b[38;2;108;113;196m[\x1b[0m \x1b[38;2;181;137;0mtrue\x1b[0m" +
+		"\x1b[38;2;133;153;0m,\x1b[0m \x1b[38;2;203;75;22mnull\x1b[0m \x1b[38;2;108;113;196m]\x1b[0m"
+	assert(output == expected, fmt.Sprintf("Wrong output %q", output))
+
+	options.Colors = ColorNone
+	output = printString("[true, null]", options)
+	assert(output == "[ true, null ]", fmt.Sprintf("Should not have coloured anything, but got %q", output))
+	assert(!strings.Contains(output, "\x1b"), "Should not have written any escape sequence")
+}
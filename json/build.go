@@ -0,0 +1,237 @@
+package json
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+// Nodes built or changed by the functions in this file have no position in any
+// input, so their Pos and End are the zero scanner.Position.
+
+// quoteString returns s as the content of a JSON string token
+func quoteString(s string) string {
+	var buffer bytes.Buffer
+	buffer.WriteRune('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&buffer, `\u%04x`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteRune('"')
+	return buffer.String()
+}
+
+// NewString returns a node holding the string s
+func NewString(s string) *ValueNode {
+	return &ValueNode{Token{Content: quoteString(s), TokenType: JSONString, Value: s}}
+}
+
+// NewNumber returns a node holding the number written as text, which has to
+// follow the JSON number grammar.
+func NewNumber(text string) (*ValueNode, error) {
+	var s scanner.Scanner
+	tokenizer := NewTokenizer(s.Init(strings.NewReader(text)))
+	token, _ := tokenizer.Scan()
+	if token.TokenType == JSONInvalid {
+		return nil, tokenizer.Err()
+	}
+	if token.TokenType != JSONNumber || token.Position.Offset != 0 {
+		return nil, unexpected(&tokenizer, token, JSONNumber)
+	}
+	if end, _ := tokenizer.Scan(); end.TokenType != JSONEnd {
+		return nil, unexpected(&tokenizer, end, JSONEnd)
+	}
+	return &ValueNode{Token{Content: token.Content, TokenType: JSONNumber}}, nil
+}
+
+// NewInt returns a node holding the number i
+func NewInt(i int64) *ValueNode {
+	return &ValueNode{Token{Content: strconv.FormatInt(i, 10), TokenType: JSONNumber}}
+}
+
+// NewFloat returns a node holding the number f. It fails for infinities and
+// NaN, which JSON has no way of writing.
+func NewFloat(f float64) (*ValueNode, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("%v can not be written as a JSON number", f)
+	}
+	return &ValueNode{Token{Content: strconv.FormatFloat(f, 'g', -1, 64), TokenType: JSONNumber}}, nil
+}
+
+// NewBool returns a node holding true or false
+func NewBool(b bool) *ValueNode {
+	if b {
+		return &ValueNode{Token{Content: "true", TokenType: JSONTrue}}
+	}
+	return &ValueNode{Token{Content: "false", TokenType: JSONFalse}}
+}
+
+// NewNull returns a node holding null
+func NewNull() *ValueNode {
+	return &ValueNode{Token{Content: "null", TokenType: JSONNull}}
+}
+
+// NewObject returns an object with no properties
+func NewObject() *ObjectNode {
+	return &ObjectNode{}
+}
+
+// NewArray returns an array holding the given elements
+func NewArray(elements ...Node) *ArrayNode {
+	return &ArrayNode{elements: append([]Node{}, elements...)}
+}
+
+func newProperty(key string, value Node) *PropertyNode {
+	return &PropertyNode{NewString(key).token, value}
+}
+
+// Len returns the number of properties of the object
+func (o *ObjectNode) Len() int {
+	return len(o.properties)
+}
+
+// Get returns the value of the first property named key
+func (o *ObjectNode) Get(key string) (Node, bool) {
+	for _, property := range o.properties {
+		if property.Key() == key {
+			return property.value, true
+		}
+	}
+	return nil, false
+}
+
+// Set replaces the value of the first property named key, or adds a property
+// at the end of the object if there is none.
+func (o *ObjectNode) Set(key string, value Node) {
+	for _, property := range o.properties {
+		if property.Key() == key {
+			property.value = value
+			return
+		}
+	}
+	o.properties = append(o.properties, newProperty(key, value))
+}
+
+// Insert adds a property at index i, moving the properties from i onwards back
+// by one. It panics if i is out of range.
+func (o *ObjectNode) Insert(i int, key string, value Node) {
+	if i < 0 || i > len(o.properties) {
+		panic(fmt.Sprintf("index %d out of range [0:%d]", i, len(o.properties)))
+	}
+	o.properties = append(o.properties, nil)
+	copy(o.properties[i+1:], o.properties[i:])
+	o.properties[i] = newProperty(key, value)
+}
+
+// Delete removes every property named key, and returns whether there were any.
+func (o *ObjectNode) Delete(key string) bool {
+	kept := o.properties[:0]
+	for _, property := range o.properties {
+		if property.Key() != key {
+			kept = append(kept, property)
+		}
+	}
+	deleted := len(kept) < len(o.properties)
+	// Don't hold on to the deleted properties
+	for i := len(kept); i < len(o.properties); i++ {
+		o.properties[i] = nil
+	}
+	o.properties = kept
+	return deleted
+}
+
+// Move moves the property at index from to index to, shifting the properties
+// in between. It panics if either index is out of range.
+func (o *ObjectNode) Move(from, to int) {
+	property := o.properties[from]
+	if from < to {
+		copy(o.properties[from:to], o.properties[from+1:to+1])
+	} else {
+		copy(o.properties[to+1:from+1], o.properties[to:from])
+	}
+	o.properties[to] = property
+}
+
+// Sort reorders the properties with less, keeping properties that are equal in
+// their original order.
+func (o *ObjectNode) Sort(less func(a, b *PropertyNode) bool) {
+	sort.SliceStable(o.properties, func(i, j int) bool {
+		return less(o.properties[i], o.properties[j])
+	})
+}
+
+// SortKeys reorders the properties by key
+func (o *ObjectNode) SortKeys() {
+	o.Sort(func(a, b *PropertyNode) bool {
+		return a.Key() < b.Key()
+	})
+}
+
+// Len returns the number of elements of the array
+func (a *ArrayNode) Len() int {
+	return len(a.elements)
+}
+
+// Append adds elements to the end of the array
+func (a *ArrayNode) Append(elements ...Node) {
+	a.elements = append(a.elements, elements...)
+}
+
+// Insert adds an element at index i, moving the elements from i onwards back by
+// one. It panics if i is out of range.
+func (a *ArrayNode) Insert(i int, element Node) {
+	if i < 0 || i > len(a.elements) {
+		panic(fmt.Sprintf("index %d out of range [0:%d]", i, len(a.elements)))
+	}
+	a.elements = append(a.elements, nil)
+	copy(a.elements[i+1:], a.elements[i:])
+	a.elements[i] = element
+}
+
+// Set replaces the element at index i. It panics if i is out of range.
+func (a *ArrayNode) Set(i int, element Node) {
+	a.elements[i] = element
+}
+
+// Delete removes the element at index i. It panics if i is out of range.
+func (a *ArrayNode) Delete(i int) {
+	copy(a.elements[i:], a.elements[i+1:])
+	a.elements[len(a.elements)-1] = nil
+	a.elements = a.elements[:len(a.elements)-1]
+}
+
+// Move moves the element at index from to index to, shifting the elements in
+// between. It panics if either index is out of range.
+func (a *ArrayNode) Move(from, to int) {
+	element := a.elements[from]
+	if from < to {
+		copy(a.elements[from:to], a.elements[from+1:to+1])
+	} else {
+		copy(a.elements[to+1:from+1], a.elements[to:from])
+	}
+	a.elements[to] = element
+}
@@ -0,0 +1,72 @@
+package json
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func keys(o *ObjectNode) string {
+	var names []string
+	for _, property := range o.Properties() {
+		names = append(names, property.Key())
+	}
+	return strings.Join(names, ",")
+}
+
+func TestBuild(t *testing.T) {
+	for _, s := range []string{"", "abc", "a\"b\\c", "line\nbreak\ttab", "\x01\x1f", "日本", "</script>"} {
+		token := scanOne(NewString(s).Token().Content)
+		assert(token.TokenType == JSONString, fmt.Sprintf("%q should have been written as a valid string", s))
+		assert(token.Value == s, fmt.Sprintf("%q should have round tripped, but got %q", s, token.Value))
+	}
+
+	n, err := NewNumber("-1.5e3")
+	assert(err == nil && n.Kind() == NumberKind, fmt.Sprintf("Should have made a number, but got %v", err))
+	for _, text := range []string{"", "1.", "01", "1 2", " 1", "\"1\"", "x"} {
+		_, err = NewNumber(text)
+		assert(err != nil, fmt.Sprintf("%q should not have been a number", text))
+	}
+	assert(NewInt(-42).Token().Content == "-42", "Should have written -42")
+	f, err := NewFloat(0.5)
+	assert(err == nil && f.Token().Content == "0.5", "Should have written 0.5")
+	_, err = NewFloat(1 / zero())
+	assert(err != nil, "Infinity should not have been a number")
+	assert(NewBool(true).Kind() == BoolKind && NewBool(false).Token().Content == "false", "Should have made booleans")
+	assert(NewNull().Kind() == NullKind, "Should have made null")
+
+	o := NewObject()
+	o.Set("b", NewInt(1))
+	o.Set("a", NewInt(2))
+	o.Set("b", NewInt(3))
+	assert(keys(o) == "b,a", fmt.Sprintf("Wrong keys %s", keys(o)))
+	v, ok := o.Get("b")
+	assert(ok && v.(*ValueNode).Token().Content == "3", "Set should have replaced the value of b")
+	o.Insert(0, "c\"", NewNull())
+	assert(keys(o) == "c\",b,a", fmt.Sprintf("Wrong keys %s", keys(o)))
+	assert(o.Properties()[0].KeyToken().Content == "\"c\\\"\"", "The key token should have been escaped")
+	o.Move(0, 2)
+	assert(keys(o) == "b,a,c\"", fmt.Sprintf("Wrong keys after moving %s", keys(o)))
+	o.Move(2, 0)
+	assert(keys(o) == "c\",b,a", fmt.Sprintf("Wrong keys after moving back %s", keys(o)))
+	o.SortKeys()
+	assert(keys(o) == "a,b,c\"", fmt.Sprintf("Wrong keys after sorting %s", keys(o)))
+	assert(o.Delete("b") && !o.Delete("b"), "Should have deleted b once")
+	assert(o.Len() == 2, "Should have had 2 properties left")
+
+	a := NewArray(NewInt(0), NewInt(1))
+	a.Append(NewInt(2), NewInt(3))
+	a.Insert(0, NewString("first"))
+	a.Set(1, NewBool(true))
+	a.Delete(4)
+	a.Move(0, 3)
+	var contents []string
+	for _, element := range a.Elements() {
+		contents = append(contents, element.(*ValueNode).Token().Content)
+	}
+	assert(strings.Join(contents, ",") == "true,1,2,\"first\"", fmt.Sprintf("Wrong elements %v", contents))
+}
+
+func zero() float64 {
+	return 0
+}
@@ -0,0 +1,496 @@
+package json
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	stdjson "encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+// ToInterface converts the tree to the Go values that encoding/json decodes
+// into an interface{}: map[string]interface{}, []interface{}, string, float64,
+// bool and nil.
+func ToInterface(node Node) (interface{}, error) {
+	switch node := node.(type) {
+	case *ObjectNode:
+		m := make(map[string]interface{}, len(node.properties))
+		for _, property := range node.properties {
+			value, err := ToInterface(property.value)
+			if err != nil {
+				return nil, err
+			}
+			m[property.Key()] = value
+		}
+		return m, nil
+	case *ArrayNode:
+		a := make([]interface{}, 0, len(node.elements))
+		for _, element := range node.elements {
+			value, err := ToInterface(element)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, value)
+		}
+		return a, nil
+	case *PropertyNode:
+		return ToInterface(node.value)
+	case *ValueNode:
+		switch node.token.TokenType {
+		case JSONString:
+			return node.token.Value, nil
+		case JSONNumber:
+			return node.Float64()
+		case JSONTrue:
+			return true, nil
+		case JSONFalse:
+			return false, nil
+		case JSONNull:
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("can not convert %T to a Go value", node)
+}
+
+// writeCompact writes the tree as JSON text, without any whitespace
+func writeCompact(buffer *bytes.Buffer, node Node) error {
+	switch node := node.(type) {
+	case *ObjectNode:
+		buffer.WriteRune('{')
+		for i, property := range node.properties {
+			if i > 0 {
+				buffer.WriteRune(',')
+			}
+			buffer.WriteString(property.key.Content)
+			buffer.WriteRune(':')
+			if err := writeCompact(buffer, property.value); err != nil {
+				return err
+			}
+		}
+		buffer.WriteRune('}')
+	case *ArrayNode:
+		buffer.WriteRune('[')
+		for i, element := range node.elements {
+			if i > 0 {
+				buffer.WriteRune(',')
+			}
+			if err := writeCompact(buffer, element); err != nil {
+				return err
+			}
+		}
+		buffer.WriteRune(']')
+	case *PropertyNode:
+		return writeCompact(buffer, node.value)
+	case *ValueNode:
+		buffer.WriteString(node.token.Content)
+	default:
+		return fmt.Errorf("can not write %T as JSON", node)
+	}
+	return nil
+}
+
+// Compact returns the tree as JSON text, without any whitespace
+func Compact(node Node) (string, error) {
+	var buffer bytes.Buffer
+	err := writeCompact(&buffer, node)
+	return buffer.String(), err
+}
+
+// Unmarshal stores the tree in the value pointed to by target, following the
+// same rules as encoding/json.Unmarshal, struct tags included.
+func Unmarshal(node Node, target interface{}) error {
+	var buffer bytes.Buffer
+	if err := writeCompact(&buffer, node); err != nil {
+		return err
+	}
+	return stdjson.Unmarshal(buffer.Bytes(), target)
+}
+
+var (
+	marshalerType     = reflect.TypeOf((*stdjson.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	rawMessageType    = reflect.TypeOf(stdjson.RawMessage{})
+	stdNumberType     = reflect.TypeOf(stdjson.Number(""))
+	numberType        = reflect.TypeOf(Number(""))
+)
+
+// FromInterface builds a tree out of a Go value, following the same rules as
+// encoding/json.Marshal: maps are written with sorted keys, structs honour
+// their json tags, and json.Marshaler and encoding.TextMarshaler are used
+// where they are implemented. A value that contains itself is an error.
+func FromInterface(v interface{}) (Node, error) {
+	c := converter{visiting: map[visit]bool{}}
+	return c.fromValue(reflect.ValueOf(v))
+}
+
+// parseBytes parses JSON text written by a json.Marshaler
+func parseBytes(data []byte) (Node, error) {
+	var s scanner.Scanner
+	tokenizer := NewTokenizer(s.Init(bytes.NewReader(data)))
+	return Parse(&tokenizer)
+}
+
+// implementer returns v, or its address, if either implements t
+func implementer(v reflect.Value, t reflect.Type) (reflect.Value, bool) {
+	if v.Type().Implements(t) && v.CanInterface() {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return v, false
+		}
+		return v, true
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(t) && v.Addr().CanInterface() {
+		return v.Addr(), true
+	}
+	return v, false
+}
+
+// visit identifies a pointer, map or slice being converted
+type visit struct {
+	t       reflect.Type
+	pointer uintptr
+	length  int
+}
+
+// converter builds a tree out of a Go value. It keeps the pointers, maps and
+// slices it is in the middle of converting, to tell when a value contains
+// itself.
+type converter struct {
+	visiting map[visit]bool
+}
+
+// enter marks the pointer, map or slice v as being converted, and returns a
+// function that unmarks it. It fails if v is being converted already, as it
+// would then be converted forever.
+func (c *converter) enter(v reflect.Value) (func(), error) {
+	key := visit{t: v.Type(), pointer: v.Pointer()}
+	if v.Kind() == reflect.Slice {
+		key.length = v.Len()
+	}
+	if c.visiting[key] {
+		return nil, fmt.Errorf("can not convert %s to JSON, as it contains itself", v.Type())
+	}
+	c.visiting[key] = true
+	return func() { delete(c.visiting, key) }, nil
+}
+
+func (c *converter) fromValue(v reflect.Value) (Node, error) {
+	if !v.IsValid() {
+		return NewNull(), nil
+	}
+
+	switch v.Type() {
+	case rawMessageType:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		return parseBytes(v.Bytes())
+	case numberType, stdNumberType:
+		return NewNumber(v.String())
+	}
+	if m, ok := implementer(v, marshalerType); ok {
+		data, err := m.Interface().(stdjson.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return parseBytes(data)
+	}
+	if m, ok := implementer(v, textMarshalerType); ok {
+		text, err := m.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return NewString(string(text)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return NewBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInt(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewNumber(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32:
+		// Write float32s with as few digits as they need, rather than as float64s
+		f := v.Float()
+		if _, err := NewFloat(f); err != nil {
+			return nil, err
+		}
+		return NewNumber(strconv.FormatFloat(f, 'g', -1, 32))
+	case reflect.Float64:
+		return NewFloat(v.Float())
+	case reflect.String:
+		return NewString(v.String()), nil
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		if v.Kind() == reflect.Ptr {
+			leave, err := c.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		return c.fromValue(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return NewString(base64.StdEncoding.EncodeToString(v.Bytes())), nil
+		}
+		leave, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return c.fromArray(v)
+	case reflect.Array:
+		return c.fromArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		leave, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return c.fromMap(v)
+	case reflect.Struct:
+		return c.fromStruct(v)
+	}
+	return nil, fmt.Errorf("can not convert %s to JSON", v.Type())
+}
+
+func (c *converter) fromArray(v reflect.Value) (Node, error) {
+	node := NewArray()
+	for i := 0; i < v.Len(); i++ {
+		element, err := c.fromValue(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		node.Append(element)
+	}
+	return node, nil
+}
+
+func mapKey(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+	if m, ok := implementer(key, textMarshalerType); ok {
+		text, err := m.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("can not use %s as an object key", key.Type())
+}
+
+func (c *converter) fromMap(v reflect.Value) (Node, error) {
+	keys := make([]string, 0, v.Len())
+	values := make(map[string]reflect.Value, v.Len())
+	for _, key := range v.MapKeys() {
+		name, err := mapKey(key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, name)
+		values[name] = v.MapIndex(key)
+	}
+	sort.Strings(keys)
+
+	// The keys are unique already, so there is no need for Set to look for them
+	node := NewObject()
+	node.properties = make([]*PropertyNode, 0, len(keys))
+	for _, key := range keys {
+		value, err := c.fromValue(values[key])
+		if err != nil {
+			return nil, err
+		}
+		node.properties = append(node.properties, newProperty(key, value))
+	}
+	return node, nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// structField is a field of a struct, or of the structs embedded in it, that
+// is written to JSON
+type structField struct {
+	name string
+	// index leads to the field through the embedded structs, as for
+	// reflect.Value.FieldByIndex
+	index             []int
+	tagged            bool
+	omitEmpty, quoted bool
+}
+
+// structFields returns the fields of the struct type t that encoding/json
+// would write, in order. As with encoding/json, the fields of embedded structs
+// are promoted, and of the fields with the same name, the shallowest one wins,
+// then the one with a json tag. If that still leaves several, all of them are
+// dropped.
+func structFields(t reflect.Type) []structField {
+	type embedded struct {
+		t     reflect.Type
+		index []int
+	}
+	var fields []structField
+	// Structs are read a depth at a time, counting how many times each was
+	// embedded at the depth
+	next := []embedded{{t, nil}}
+	count, nextCount := map[reflect.Type]int{}, map[reflect.Type]int{t: 1}
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		for _, s := range current {
+			// A struct already read less deeply has fields that win over these
+			if visited[s.t] {
+				continue
+			}
+			visited[s.t] = true
+			for i := 0; i < s.t.NumField(); i++ {
+				field := s.t.Field(i)
+				fieldType := field.Type
+				if fieldType.Kind() == reflect.Ptr {
+					fieldType = fieldType.Elem()
+				}
+				if field.PkgPath != "" && !(field.Anonymous && fieldType.Kind() == reflect.Struct) {
+					// Unexported, but for the fields of unexported embedded structs
+					continue
+				}
+				tag := field.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, options := tag, ""
+				if comma := strings.IndexRune(tag, ','); comma >= 0 {
+					name, options = tag[:comma], tag[comma:]
+				}
+				index := append(append([]int{}, s.index...), i)
+
+				if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+					nextCount[fieldType]++
+					if nextCount[fieldType] == 1 {
+						next = append(next, embedded{fieldType, index})
+					}
+					continue
+				}
+				f := structField{
+					name:      name,
+					index:     index,
+					tagged:    name != "",
+					omitEmpty: strings.Contains(options, ",omitempty"),
+					quoted:    strings.Contains(options, ",string"),
+				}
+				if f.name == "" {
+					f.name = field.Name
+				}
+				fields = append(fields, f)
+				if count[s.t] > 1 {
+					// The struct was embedded more than once at this depth, so
+					// its fields clash with themselves
+					fields = append(fields, f)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].name != fields[j].name {
+			return fields[i].name < fields[j].name
+		}
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		return fields[i].tagged && !fields[j].tagged
+	})
+	var dominant []structField
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if j == i+1 || len(fields[i+1].index) > len(fields[i].index) || fields[i].tagged && !fields[i+1].tagged {
+			dominant = append(dominant, fields[i])
+		}
+		i = j
+	}
+	sort.Slice(dominant, func(i, j int) bool {
+		a, b := dominant[i].index, dominant[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return dominant
+}
+
+// fieldByIndex returns the field of the struct v that index leads to, and
+// false if it is inside an embedded struct that a nil pointer stands for.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+func (c *converter) fromStruct(v reflect.Value) (Node, error) {
+	// The names of the fields are unique, so there is no need for Set
+	node := NewObject()
+	for _, field := range structFields(v.Type()) {
+		value, ok := fieldByIndex(v, field.index)
+		if !ok || field.omitEmpty && isEmptyValue(value) {
+			continue
+		}
+		element, err := c.fromValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := element.(*ValueNode); ok && field.quoted {
+			switch value.token.TokenType {
+			case JSONString, JSONNumber, JSONTrue, JSONFalse:
+				element = NewString(value.token.Content)
+			}
+		}
+		node.properties = append(node.properties, newProperty(field.name, element))
+	}
+	return node, nil
+}
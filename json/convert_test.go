@@ -0,0 +1,152 @@
+package json
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type convertBase struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type convertWidget struct {
+	convertBase
+	Name    string             `json:"title"`
+	Tags    []string           `json:"tags,omitempty"`
+	Count   int64              `json:",string"`
+	Ratio   float32            `json:"ratio"`
+	Raw     stdjson.RawMessage `json:"raw"`
+	Blob    []byte             `json:"blob"`
+	Skipped string             `json:"-"`
+	hidden  string
+}
+
+type convertA struct {
+	Shared string
+	Tagged string
+	OnlyA  string
+}
+
+type convertB struct {
+	Shared string
+	Tagged string `json:"Tagged"`
+	Deep   convertC
+}
+
+type convertC struct {
+	OnlyA string
+}
+
+type convertD struct {
+	convertC
+}
+
+type convertEmbedded struct {
+	convertA
+	*convertB
+	convertD
+}
+
+type convertNode struct {
+	Value int
+	Next  *convertNode
+}
+
+func compact(node Node) string {
+	var buffer bytes.Buffer
+	err := writeCompact(&buffer, node)
+	assert(err == nil, fmt.Sprintf("Should have written the tree, but got %v", err))
+	return buffer.String()
+}
+
+func TestConvert(t *testing.T) {
+	tree := parseString("{\"a\": [1, \"x\", true, null], \"b\": {\"c\": -2.5}}")
+
+	value, err := ToInterface(tree)
+	assert(err == nil, fmt.Sprintf("Should have converted, but got %v", err))
+	expected := map[string]interface{}{
+		"a": []interface{}{1.0, "x", true, nil},
+		"b": map[string]interface{}{"c": -2.5},
+	}
+	assert(reflect.DeepEqual(value, expected), fmt.Sprintf("Wrong value %#v", value))
+
+	var target struct {
+		A []interface{}
+		B struct {
+			C float64 `json:"c"`
+		} `json:"b"`
+	}
+	err = Unmarshal(tree, &target)
+	assert(err == nil, fmt.Sprintf("Should have unmarshalled, but got %v", err))
+	assert(len(target.A) == 4 && target.B.C == -2.5, fmt.Sprintf("Wrong target %#v", target))
+
+	node, err := FromInterface(map[string]interface{}{"z": 1, "a": []interface{}{"x", nil, false}, "m": map[int]bool{2: true}})
+	assert(err == nil, fmt.Sprintf("Should have converted, but got %v", err))
+	assert(compact(node) == `{"a":["x",null,false],"m":{"2":true},"z":1}`, fmt.Sprintf("Wrong tree %s", compact(node)))
+
+	widget := convertWidget{
+		convertBase: convertBase{ID: 7, Name: "base"},
+		Name:        "widget",
+		Count:       3,
+		Ratio:       0.1,
+		Raw:         stdjson.RawMessage(`{"nested": [1]}`),
+		Blob:        []byte("hi"),
+		Skipped:     "no",
+		hidden:      "no",
+	}
+	node, err = FromInterface(&widget)
+	assert(err == nil, fmt.Sprintf("Should have converted, but got %v", err))
+	expectedText, _ := stdjson.Marshal(&widget)
+	assert(compact(node) == string(expectedText), fmt.Sprintf("Should have matched encoding/json, but got %s instead of %s", compact(node), expectedText))
+
+	_, err = FromInterface(make(chan int))
+	assert(err != nil, "Should not have converted a channel")
+}
+
+func TestConvertEmbedded(t *testing.T) {
+	// Shared is ambiguous and dropped, the tagged Tagged wins over the other,
+	// and OnlyA is the shallower of the two
+	embedded := convertEmbedded{
+		convertA: convertA{"a", "a", "a"},
+		convertB: &convertB{"b", "b", convertC{"c"}},
+		convertD: convertD{convertC{"d"}},
+	}
+	node, err := FromInterface(embedded)
+	assert(err == nil, fmt.Sprintf("Should have converted, but got %v", err))
+	expected, _ := stdjson.Marshal(embedded)
+	assert(compact(node) == string(expected), fmt.Sprintf("Should have matched encoding/json, but got %s instead of %s", compact(node), expected))
+	assert(compact(node) == `{"OnlyA":"a","Tagged":"b","Deep":{"OnlyA":"c"}}`, fmt.Sprintf("Wrong tree %s", compact(node)))
+
+	embedded.convertB = nil
+	node, err = FromInterface(embedded)
+	assert(err == nil, fmt.Sprintf("Should have converted, but got %v", err))
+	expected, _ = stdjson.Marshal(embedded)
+	assert(compact(node) == string(expected), fmt.Sprintf("Should have matched encoding/json, but got %s instead of %s", compact(node), expected))
+}
+
+func TestConvertCycle(t *testing.T) {
+	list := &convertNode{Value: 1}
+	list.Next = &convertNode{Value: 2, Next: list}
+	_, err := FromInterface(list)
+	assert(err != nil, "Should not have converted a list that loops")
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	_, err = FromInterface(m)
+	assert(err != nil, "Should not have converted a map that contains itself")
+
+	s := []interface{}{nil}
+	s[0] = s
+	_, err = FromInterface(s)
+	assert(err != nil, "Should not have converted a slice that contains itself")
+
+	// The same value twice is not a cycle
+	shared := &convertNode{Value: 3}
+	node, err := FromInterface([]*convertNode{shared, shared})
+	assert(err == nil, fmt.Sprintf("Should have converted a shared value, but got %v", err))
+	assert(compact(node) == `[{"Value":3,"Next":null},{"Value":3,"Next":null}]`, fmt.Sprintf("Wrong tree %s", compact(node)))
+}
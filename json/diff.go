@@ -0,0 +1,126 @@
+package json
+
+import "strconv"
+
+// ChangeKind is what happened to a value from one tree to the other
+type ChangeKind int
+
+const (
+	// Added is a value that only the new tree has
+	Added ChangeKind = iota
+	// Removed is a value that only the old tree has
+	Removed
+	// Changed is a value that the trees have different versions of
+	Changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return "unknown change"
+}
+
+// Change is a difference between two trees. Old is nil for an added value, and
+// New for a removed one.
+type Change struct {
+	Kind     ChangeKind
+	Path     Path
+	Old, New Node
+}
+
+// Diff returns the differences between the trees before and after. Objects are
+// compared key by key, whatever the order of their properties, and arrays
+// index by index, so inserting an element changes every element after it.
+// Numbers are compared by value, so 1.0 is the same as 1, and strings by what
+// they decode to.
+func Diff(before, after Node) []Change {
+	var changes []Change
+	diff(before, after, Path{}, &changes)
+	return changes
+}
+
+func diff(before, after Node, path Path, changes *[]Change) {
+	if property, ok := before.(*PropertyNode); ok {
+		before = property.value
+	}
+	if property, ok := after.(*PropertyNode); ok {
+		after = property.value
+	}
+	switch before := before.(type) {
+	case *ObjectNode:
+		if after, ok := after.(*ObjectNode); ok {
+			diffObjects(before, after, path, changes)
+			return
+		}
+	case *ArrayNode:
+		if after, ok := after.(*ArrayNode); ok {
+			diffArrays(before, after, path, changes)
+			return
+		}
+	case *ValueNode:
+		if after, ok := after.(*ValueNode); ok && equalValues(before, after) {
+			return
+		}
+	}
+	*changes = append(*changes, Change{Changed, path, before, after})
+}
+
+func diffObjects(before, after *ObjectNode, path Path, changes *[]Change) {
+	seen := map[string]bool{}
+	for _, property := range before.properties {
+		key := property.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if value, ok := after.Get(key); ok {
+			diff(property.value, value, path.child(key), changes)
+		} else {
+			*changes = append(*changes, Change{Removed, path.child(key), property.value, nil})
+		}
+	}
+	for _, property := range after.properties {
+		key := property.Key()
+		if !seen[key] {
+			seen[key] = true
+			*changes = append(*changes, Change{Added, path.child(key), nil, property.value})
+		}
+	}
+}
+
+func diffArrays(before, after *ArrayNode, path Path, changes *[]Change) {
+	for i, element := range before.elements {
+		if i < len(after.elements) {
+			diff(element, after.elements[i], path.child(strconv.Itoa(i)), changes)
+		} else {
+			*changes = append(*changes, Change{Removed, path.child(strconv.Itoa(i)), element, nil})
+		}
+	}
+	for i := len(before.elements); i < len(after.elements); i++ {
+		*changes = append(*changes, Change{Added, path.child(strconv.Itoa(i)), nil, after.elements[i]})
+	}
+}
+
+func equalValues(a, b *ValueNode) bool {
+	if a.token.TokenType != b.token.TokenType {
+		return false
+	}
+	switch a.token.TokenType {
+	case JSONString:
+		return a.token.Value == b.token.Value
+	case JSONNumber:
+		x, errX := a.BigFloat()
+		y, errY := b.BigFloat()
+		if errX != nil || errY != nil {
+			return a.token.Content == b.token.Content
+		}
+		return x.Cmp(y) == 0
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package json
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := parseString(`{"a": 1, "b": [1, 2, 3], "c": {"d": "x"}, "e": true, "n": 1.0, "s": "A"}`)
+	after := parseString(`{"s": "A", "n": 1, "b": [1, 5], "c": {"d": "x", "f": null}, "e": "true", "g": []}`)
+
+	var changes []string
+	for _, change := range Diff(before, after) {
+		changes = append(changes, fmt.Sprintf("%s %s", change.Kind, change.Path))
+	}
+	expected := []string{"removed /a", "changed /b/1", "removed /b/2", "added /c/f", "changed /e", "added /g"}
+	assert(strings.Join(changes, ", ") == strings.Join(expected, ", "), fmt.Sprintf("Wrong changes %v", changes))
+
+	assert(len(Diff(before, before)) == 0, "A tree should not have differed from itself")
+
+	changes = nil
+	for _, change := range Diff(parseString(`[1]`), parseString(`{"0": 1}`)) {
+		assert(change.Old.Kind() == ArrayKind && change.New.Kind() == ObjectKind, "Should have kept both versions")
+		changes = append(changes, fmt.Sprintf("%s %q", change.Kind, change.Path.String()))
+	}
+	assert(len(changes) == 1 && changes[0] == `changed ""`, fmt.Sprintf("Should have changed the root, but got %v", changes))
+}
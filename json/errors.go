@@ -0,0 +1,119 @@
+package json
+
+import (
+	"bytes"
+	"fmt"
+	"text/scanner"
+	"unicode/utf8"
+)
+
+// SyntaxError describes malformed input, along with where it was found
+type SyntaxError struct {
+	// Msg is a human readable description of the problem
+	Msg string
+	// Position is where in the input the problem was found
+	Position scanner.Position
+	// Expected lists the token types that would have been valid, if known
+	Expected []int
+	// Found is the offending token
+	Found Token
+	// Line is the text of the offending line around the error, as far as it
+	// was read, with "..." where it is cut short
+	Line string
+	// lineColumn is the column of the error within Line
+	lineColumn int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Msg)
+}
+
+// Snippet returns the offending line with a caret pointing at the error, or an
+// empty string if the line is not known.
+func (e *SyntaxError) Snippet() string {
+	if e.Line == "" {
+		return ""
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(e.Line)
+	buffer.WriteRune('\n')
+	column := e.Position.Column
+	if e.lineColumn > 0 {
+		column = e.lineColumn
+	}
+	if max := utf8.RuneCountInString(e.Line) + 1; column > max {
+		column = max
+	}
+	for i, r := range []rune(e.Line) {
+		if i >= column-1 {
+			break
+		}
+		// Keep tabs so that the caret lines up with the text above it
+		if r == '\t' {
+			buffer.WriteRune('\t')
+		} else {
+			buffer.WriteRune(' ')
+		}
+	}
+	buffer.WriteRune('^')
+	return buffer.String()
+}
+
+// ErrorList is a list of syntax errors, in the order they were found
+type ErrorList []*SyntaxError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns an error equivalent to this list, or nil if the list is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+func describeToken(token Token) string {
+	switch token.TokenType {
+	case JSONEnd:
+		return tokenName(JSONEnd)
+	case JSONTrue, JSONFalse, JSONNull:
+		return token.Content
+	case JSONString, JSONNumber:
+		return fmt.Sprintf("%s %s", tokenName(token.TokenType), token.Content)
+	}
+	return fmt.Sprintf("%q", token.Content)
+}
+
+// unexpected reports that token is not one of the expected token types. Tokens
+// the tokenizer already rejected are reported with the tokenizer's own error.
+func unexpected(tokenizer *Tokenizer, token Token, expected ...int) *SyntaxError {
+	if token.TokenType == JSONInvalid && tokenizer.err != nil {
+		return tokenizer.err
+	}
+	var buffer bytes.Buffer
+	for i, tokenType := range expected {
+		if i > 0 && i == len(expected)-1 {
+			buffer.WriteString(" or ")
+		} else if i > 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(tokenName(tokenType))
+	}
+	line, column := tokenizer.lineAt(token.Position)
+	return &SyntaxError{
+		Msg:        fmt.Sprintf("expected %s, found %s", buffer.String(), describeToken(token)),
+		Position:   token.Position,
+		Expected:   expected,
+		Found:      token,
+		Line:       line,
+		lineColumn: column,
+	}
+}
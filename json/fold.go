@@ -0,0 +1,40 @@
+package json
+
+// FoldStylesheet is the CSS that the folds of a printer with Collapsible set
+// need, on top of the colours.
+const FoldStylesheet = `.j-toggle { cursor: pointer; }
+.j-summary { display: none; opacity: 0.6; }
+.j-folded > .j-body { display: none; }
+.j-folded > .j-summary { display: inline; }
+.j-controls { font-family: sans-serif; margin-bottom: 5px; }
+`
+
+// FoldScript is the JavaScript that folds and unfolds containers when their
+// brace or bracket is clicked, and that FoldControls call.
+const FoldScript = `document.addEventListener('click', function (event) {
+  var toggle = event.target.closest && event.target.closest('.j-toggle');
+  if (toggle) {
+    toggle.parentNode.classList.toggle('j-folded');
+  }
+});
+function jsonFold(shouldFold) {
+  document.querySelectorAll('.j-fold').forEach(function (fold) {
+    fold.classList.toggle('j-folded', shouldFold(Number(fold.getAttribute('data-depth'))));
+  });
+}
+function jsonFoldAll(folded) {
+  jsonFold(function () { return folded; });
+}
+function jsonFoldToDepth(depth) {
+  jsonFold(function (foldDepth) { return foldDepth > depth; });
+}
+`
+
+// FoldControls is the HTML of buttons that unfold or fold every container, and
+// of a field that folds the containers deeper than a depth.
+const FoldControls = `<div class='j-controls'>
+  <button type='button' onclick='jsonFoldAll(false)'>Expand all</button>
+  <button type='button' onclick='jsonFoldAll(true)'>Collapse all</button>
+  <label>Collapse to depth <input type='number' min='0' value='0' onchange='jsonFoldToDepth(Number(this.value))'></label>
+</div>
+`
@@ -0,0 +1,99 @@
+package json
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Number is the exact text of a JSON number, as it appeared in the input
+type Number string
+
+// String returns the text of the number
+func (n Number) String() string {
+	return string(n)
+}
+
+// Int64 returns the number as an int64. It fails if the number has a fraction
+// or an exponent, or does not fit.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Float64 returns the number as the nearest float64
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// BigFloat returns the number as a big.Float, with enough precision to hold
+// every digit of the number.
+func (n Number) BigFloat() (*big.Float, error) {
+	// Each decimal digit takes a little over 3 bits
+	precision := uint(64)
+	if bits := uint(len(n)) * 4; bits > precision {
+		precision = bits
+	}
+	f, _, err := big.ParseFloat(string(n), 10, precision, big.ToNearestEven)
+	return f, err
+}
+
+// BigInt returns the number as a big.Int. It fails if the number is not a whole
+// number, so 1e3 is fine but 1.5 is not.
+func (n Number) BigInt() (*big.Int, error) {
+	if i, ok := new(big.Int).SetString(string(n), 10); ok {
+		return i, nil
+	}
+	f, err := n.BigFloat()
+	if err != nil {
+		return nil, err
+	}
+	if !f.IsInt() {
+		return nil, fmt.Errorf("%s is not an integer", n)
+	}
+	i, _ := f.Int(nil)
+	return i, nil
+}
+
+// Number returns the exact text of the value, if it is a number
+func (v *ValueNode) Number() (Number, error) {
+	if v.token.TokenType != JSONNumber {
+		return "", fmt.Errorf("%s is not a number", describeToken(v.token))
+	}
+	return Number(v.token.Content), nil
+}
+
+// Int64 returns the value as an int64, if it is a number that fits
+func (v *ValueNode) Int64() (int64, error) {
+	n, err := v.Number()
+	if err != nil {
+		return 0, err
+	}
+	return n.Int64()
+}
+
+// Float64 returns the value as a float64, if it is a number
+func (v *ValueNode) Float64() (float64, error) {
+	n, err := v.Number()
+	if err != nil {
+		return 0, err
+	}
+	return n.Float64()
+}
+
+// BigInt returns the value as a big.Int, if it is a whole number
+func (v *ValueNode) BigInt() (*big.Int, error) {
+	n, err := v.Number()
+	if err != nil {
+		return nil, err
+	}
+	return n.BigInt()
+}
+
+// BigFloat returns the value as a big.Float, if it is a number
+func (v *ValueNode) BigFloat() (*big.Float, error) {
+	n, err := v.Number()
+	if err != nil {
+		return nil, err
+	}
+	return n.BigFloat()
+}
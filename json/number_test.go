@@ -0,0 +1,38 @@
+package json
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNumber(t *testing.T) {
+	i, err := parseString("-42").(*ValueNode).Int64()
+	assert(err == nil && i == -42, fmt.Sprintf("Should have been -42, but got %d (%v)", i, err))
+
+	_, err = parseString("1.5").(*ValueNode).Int64()
+	assert(err != nil, "1.5 should not have been an int64")
+
+	_, err = parseString("9223372036854775808").(*ValueNode).Int64()
+	assert(err != nil, "Should have overflowed an int64")
+
+	f, err := parseString("-1.5e2").(*ValueNode).Float64()
+	assert(err == nil && f == -150, fmt.Sprintf("Should have been -150, but got %f (%v)", f, err))
+
+	b, err := parseString("123456789012345678901234567890").(*ValueNode).BigInt()
+	assert(err == nil && b.String() == "123456789012345678901234567890", fmt.Sprintf("Wrong big int %v (%v)", b, err))
+
+	b, err = parseString("1e3").(*ValueNode).BigInt()
+	assert(err == nil && b.Int64() == 1000, fmt.Sprintf("Should have been 1000, but got %v (%v)", b, err))
+
+	_, err = parseString("1.5").(*ValueNode).BigInt()
+	assert(err != nil, "1.5 should not have been a big int")
+
+	bf, err := parseString("0.1").(*ValueNode).BigFloat()
+	assert(err == nil && bf.Text('g', 10) == "0.1", fmt.Sprintf("Should have been 0.1, but got %v (%v)", bf, err))
+
+	n, err := parseString("1.10").(*ValueNode).Number()
+	assert(err == nil && n == "1.10", "Should have kept the exact text of the number")
+
+	_, err = parseString("\"1\"").(*ValueNode).Number()
+	assert(err != nil, "A string should not have been a number")
+}
@@ -1,39 +1,143 @@
 package json
 
+import (
+	"fmt"
+	"text/scanner"
+)
+
+// NodeKind identifies the kind of a Node
+type NodeKind int
+
+const (
+	// ObjectKind is the kind of an ObjectNode
+	ObjectKind NodeKind = iota
+	// ArrayKind is the kind of an ArrayNode
+	ArrayKind
+	// PropertyKind is the kind of a PropertyNode
+	PropertyKind
+	// StringKind is the kind of a ValueNode holding a string
+	StringKind
+	// NumberKind is the kind of a ValueNode holding a number
+	NumberKind
+	// BoolKind is the kind of a ValueNode holding true or false
+	BoolKind
+	// NullKind is the kind of a ValueNode holding null
+	NullKind
+)
+
+var kindNames = map[NodeKind]string{
+	ObjectKind:   "object",
+	ArrayKind:    "array",
+	PropertyKind: "property",
+	StringKind:   "string",
+	NumberKind:   "number",
+	BoolKind:     "bool",
+	NullKind:     "null",
+}
+
+func (k NodeKind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
 // Node the base node type
 type Node interface {
-	GetType() string
+	// Kind returns the kind of the node
+	Kind() NodeKind
+	// Pos returns the position of the first character of the node
+	Pos() scanner.Position
+	// End returns the position immediately after the node
+	End() scanner.Position
 }
 
 // PropertyNode represents a property of an object
 type PropertyNode struct {
-	name  string
-	value *Node
+	key   Token
+	value Node
+}
+
+// Kind returns PropertyKind
+func (p *PropertyNode) Kind() NodeKind {
+	return PropertyKind
+}
+
+// Pos returns the position of the key
+func (p *PropertyNode) Pos() scanner.Position {
+	return p.key.Position
 }
 
-// GetType returns the string PropertyNode
-func (p PropertyNode) GetType() string {
-	return "PropertyNode"
+// End returns the position immediately after the value
+func (p *PropertyNode) End() scanner.Position {
+	return p.value.End()
+}
+
+// Key returns the decoded name of the property
+func (p *PropertyNode) Key() string {
+	return p.key.Value
+}
+
+// KeyToken returns the token of the name of the property, as it was read
+func (p *PropertyNode) KeyToken() Token {
+	return p.key
+}
+
+// Value returns the value of the property
+func (p *PropertyNode) Value() Node {
+	return p.value
 }
 
 // ObjectNode represents an object
 type ObjectNode struct {
 	properties []*PropertyNode
+	pos, end   scanner.Position
 }
 
-// GetType returns the string ObjectNode
-func (o ObjectNode) GetType() string {
-	return "ObjectNode"
+// Kind returns ObjectKind
+func (o *ObjectNode) Kind() NodeKind {
+	return ObjectKind
+}
+
+// Pos returns the position of the opening brace
+func (o *ObjectNode) Pos() scanner.Position {
+	return o.pos
+}
+
+// End returns the position immediately after the closing brace
+func (o *ObjectNode) End() scanner.Position {
+	return o.end
+}
+
+// Properties returns the properties of the object, in order
+func (o *ObjectNode) Properties() []*PropertyNode {
+	return o.properties
 }
 
 // ArrayNode represents an array
 type ArrayNode struct {
-	elements []*Node
+	elements []Node
+	pos, end scanner.Position
+}
+
+// Kind returns ArrayKind
+func (a *ArrayNode) Kind() NodeKind {
+	return ArrayKind
 }
 
-// GetType returns the string ArrayNode
-func (a ArrayNode) GetType() string {
-	return "ArrayNode"
+// Pos returns the position of the opening bracket
+func (a *ArrayNode) Pos() scanner.Position {
+	return a.pos
+}
+
+// End returns the position immediately after the closing bracket
+func (a *ArrayNode) End() scanner.Position {
+	return a.end
+}
+
+// Elements returns the elements of the array, in order
+func (a *ArrayNode) Elements() []Node {
+	return a.elements
 }
 
 // ValueNode represents a leaf value node
@@ -41,71 +145,286 @@ type ValueNode struct {
 	token Token
 }
 
-// GetType returns the string ValueNode
-func (a ValueNode) GetType() string {
-	return "ValueNode"
+var valueKinds = map[int]NodeKind{
+	JSONString: StringKind,
+	JSONNumber: NumberKind,
+	JSONTrue:   BoolKind,
+	JSONFalse:  BoolKind,
+	JSONNull:   NullKind,
+}
+
+// Kind returns the kind of value held by the node
+func (v *ValueNode) Kind() NodeKind {
+	return valueKinds[v.token.TokenType]
+}
+
+// Pos returns the position of the value
+func (v *ValueNode) Pos() scanner.Position {
+	return v.token.Position
 }
 
-func parseObject(tokenizer *Tokenizer) ObjectNode {
-	var node ObjectNode
+// End returns the position immediately after the value
+func (v *ValueNode) End() scanner.Position {
+	return v.token.End
+}
+
+// Token returns the token of the value, as it was read
+func (v *ValueNode) Token() Token {
+	return v.token
+}
+
+// maxDepth is how deeply objects and arrays can be nested. Deeper input is
+// rejected rather than parsed at the risk of running out of stack.
+const maxDepth = 10000
+
+// parser holds the state of a single parse. In tolerant mode errors are
+// collected rather than returned, and the parser resynchronises at the next
+// ',', '}' or ']' to carry on building the tree.
+type parser struct {
+	tokenizer *Tokenizer
+	tolerant  bool
+	errors    ErrorList
+	// open counts the containers being parsed, keyed by their closing token
+	open map[int]int
+	// depth is how many objects and arrays are being parsed
+	depth int
+}
 
+func newParser(tokenizer *Tokenizer, tolerant bool) *parser {
+	return &parser{tokenizer: tokenizer, tolerant: tolerant, open: map[int]int{}}
+}
+
+// fail records err. It returns err if parsing should stop, and nil if the
+// parser should recover and carry on.
+func (p *parser) fail(err *SyntaxError) error {
+	// Recovering can trip over the same token more than once; only report it once
+	if n := len(p.errors); n == 0 || p.errors[n-1].Position.Offset != err.Position.Offset {
+		p.errors = append(p.errors, err)
+	}
+	if p.tolerant {
+		return nil
+	}
+	return err
+}
+
+func isSyncToken(tokenType int) bool {
+	switch tokenType {
+	case JSONComma, JSONCloseBrace, JSONCloseSquareBracket, JSONEnd:
+		return true
+	}
+	return false
+}
+
+// skip discards tokens, starting with the offending token, until the next
+// ',', '}' or ']' that is not nested inside whatever was skipped.
+func (p *parser) skip(token Token) {
+	if isSyncToken(token.TokenType) {
+		p.tokenizer.unread(token)
+		return
+	}
+	depth := 0
+	if token.TokenType == JSONOpenBrace || token.TokenType == JSONOpenSquareBracket {
+		depth++
+	}
+	for {
+		next := p.tokenizer.Peek()
+		if next.TokenType == JSONEnd || depth == 0 && isSyncToken(next.TokenType) {
+			return
+		}
+		p.tokenizer.Scan()
+		switch next.TokenType {
+		case JSONOpenBrace, JSONOpenSquareBracket:
+			depth++
+		case JSONCloseBrace, JSONCloseSquareBracket:
+			depth--
+		}
+	}
+}
+
+// separator reads what follows a property or an element: either a comma, or
+// the closing token of the container. Once the container is closed, it returns
+// true along with the position where the container ends.
+func (p *parser) separator(closing int) (bool, scanner.Position, error) {
+	for {
+		token, _ := p.tokenizer.Scan()
+		switch token.TokenType {
+		case closing:
+			return true, token.End, nil
+		case JSONComma:
+			return false, token.End, nil
+		}
+		if err := p.fail(unexpected(p.tokenizer, token, JSONComma, closing)); err != nil {
+			return true, token.Position, err
+		}
+		switch token.TokenType {
+		case JSONEnd:
+			p.tokenizer.unread(token)
+			return true, token.Position, nil
+		case JSONCloseBrace, JSONCloseSquareBracket:
+			// A mismatched closing token closes an enclosing container if there is
+			// one to close, and is dropped otherwise.
+			if p.open[token.TokenType] > 0 {
+				p.tokenizer.unread(token)
+				return true, token.Position, nil
+			}
+		default:
+			p.skip(token)
+		}
+	}
+}
+
+// nest enters the object or array that token opens. Past maxDepth, it returns
+// an error that ends the parse, even in tolerant mode.
+func (p *parser) nest(token Token) error {
+	if p.depth == maxDepth {
+		line, column := p.tokenizer.lineAt(token.Position)
+		err := &SyntaxError{
+			Msg:        fmt.Sprintf("nested more deeply than %d levels", maxDepth),
+			Position:   token.Position,
+			Found:      token,
+			Line:       line,
+			lineColumn: column,
+		}
+		p.fail(err)
+		return err
+	}
+	p.depth++
+	return nil
+}
+
+func (p *parser) parseObject(open Token) (*ObjectNode, error) {
+	node := &ObjectNode{pos: open.Position}
+
+	if token := p.tokenizer.Peek(); token.TokenType == JSONCloseBrace {
+		p.tokenizer.Scan()
+		node.end = token.End
+		return node, nil
+	}
+	p.open[JSONCloseBrace]++
+	defer func() { p.open[JSONCloseBrace]-- }()
 	for {
-		token := tokenizer.Peek()
-		if token.TokenType == JSONCloseBrace {
-			tokenizer.Scan()
-			return node
+		key, _ := p.tokenizer.Scan()
+		if key.TokenType != JSONString {
+			if err := p.fail(unexpected(p.tokenizer, key, JSONString)); err != nil {
+				return nil, err
+			}
+			p.skip(key)
+		} else if token, _ := p.tokenizer.Scan(); token.TokenType != JSONColon {
+			if err := p.fail(unexpected(p.tokenizer, token, JSONColon)); err != nil {
+				return nil, err
+			}
+			p.skip(token)
+		} else {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			if value != nil {
+				node.properties = append(node.properties, &PropertyNode{key, value})
+			}
 		}
-		tokenizer.Scan() // Key
-		key := token
-		tokenizer.Scan() // Colon
-		value := Parse(tokenizer)
-		node.properties = append(node.properties, &PropertyNode{key.Content, &value})
-		token, _ = tokenizer.Scan()
-		if token.TokenType == JSONCloseBrace {
-			return node
+		closed, end, err := p.separator(JSONCloseBrace)
+		if err != nil {
+			return nil, err
+		} else if closed {
+			node.end = end
+			return node, nil
 		}
-		// Otherwise, we'll just assume that we have a comma
 	}
 }
 
-func parseArray(tokenizer *Tokenizer) ArrayNode {
-	var node ArrayNode
+func (p *parser) parseArray(open Token) (*ArrayNode, error) {
+	node := &ArrayNode{pos: open.Position}
+
+	if token := p.tokenizer.Peek(); token.TokenType == JSONCloseSquareBracket {
+		p.tokenizer.Scan()
+		node.end = token.End
+		return node, nil
+	}
+	p.open[JSONCloseSquareBracket]++
+	defer func() { p.open[JSONCloseSquareBracket]-- }()
 	for {
-		if tokenizer.Peek().TokenType == JSONCloseSquareBracket {
-			tokenizer.Scan()
-			return node
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			node.elements = append(node.elements, value)
 		}
-		value := Parse(tokenizer)
-		node.elements = append(node.elements, &value)
-		token, _ := tokenizer.Scan()
-		if token.TokenType == JSONCloseSquareBracket {
-			return node
+		closed, end, err := p.separator(JSONCloseSquareBracket)
+		if err != nil {
+			return nil, err
+		} else if closed {
+			node.end = end
+			return node, nil
 		}
-		assert(token.TokenType == JSONComma, "Was expecting a comma, at least!")
-		// Otherwise, we'll juse assume that we have a comma
 	}
 }
 
-// Parse parses the input tokens
-func Parse(tokenizer *Tokenizer) Node {
-	var node Node
-	token, _ := tokenizer.Scan()
+// parseValue parses the next value. In tolerant mode, it returns a nil node if
+// there was no value to be read.
+func (p *parser) parseValue() (Node, error) {
+	token, _ := p.tokenizer.Scan()
 
 	switch token.TokenType {
+	case JSONOpenBrace, JSONOpenSquareBracket:
+		if err := p.nest(token); err != nil {
+			return nil, err
+		}
+		defer func() { p.depth-- }()
+	}
 
+	switch token.TokenType {
 	case JSONOpenBrace:
-		node = parseObject(tokenizer)
+		// Careful not to return a nil *ObjectNode as a non-nil Node
+		node, err := p.parseObject(token)
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
 	case JSONOpenSquareBracket:
-		node = parseArray(tokenizer)
-	case JSONIdentifier:
-		node = ValueNode{token}
-	case JSONString:
-		node = ValueNode{token}
-	case JSONNumber:
-		node = ValueNode{token}
-	default:
-		panic("I wrote a bad parser")
-	}
-
-	return node
+		node, err := p.parseArray(token)
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	case JSONString, JSONNumber, JSONTrue, JSONFalse, JSONNull:
+		return &ValueNode{token}, nil
+	}
+	err := p.fail(unexpected(p.tokenizer, token, JSONOpenBrace, JSONOpenSquareBracket, JSONString, JSONNumber, JSONTrue, JSONFalse, JSONNull))
+	if err == nil {
+		p.skip(token)
+	}
+	return nil, err
+}
+
+func (p *parser) parse() (Node, error) {
+	node, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if token, _ := p.tokenizer.Scan(); token.TokenType != JSONEnd {
+		// There is no recovering from this, there is nothing left to parse into
+		p.fail(unexpected(p.tokenizer, token, JSONEnd))
+		if !p.tolerant {
+			return nil, p.errors[len(p.errors)-1]
+		}
+	}
+	return node, nil
+}
+
+// Parse parses a single JSON value from the input tokens. Malformed input is
+// reported as a *SyntaxError.
+func Parse(tokenizer *Tokenizer) (Node, error) {
+	return newParser(tokenizer, false).parse()
+}
+
+// ParseTolerant parses a single JSON value from the input tokens, without
+// stopping at the first error. Whatever could not be parsed is left out of the
+// returned tree, and reported in the returned list. The tree is nil if no value
+// could be read at all, or if it was nested more deeply than can be parsed.
+func ParseTolerant(tokenizer *Tokenizer) (Node, ErrorList) {
+	p := newParser(tokenizer, true)
+	node, _ := p.parse()
+	return node, p.errors
 }
@@ -12,29 +12,29 @@ func TestParse(t *testing.T) {
 	var reader *scanner.Scanner
 	var tokenizer Tokenizer
 	var tree interface{}
-	var object ObjectNode
-	var arr ArrayNode
+	var object *ObjectNode
+	var arr *ArrayNode
 	var ok bool
 
 	reader = s.Init(strings.NewReader("{}"))
 	tokenizer = NewTokenizer(reader)
-	tree = Parse(&tokenizer)
-	if _, ok := tree.(ObjectNode); !ok {
+	tree, _ = Parse(&tokenizer)
+	if _, ok := tree.(*ObjectNode); !ok {
 		panic("Should have been an object node")
 	}
 
 	reader = s.Init(strings.NewReader("{\"f\":1,\"g\":2}"))
 	tokenizer = NewTokenizer(reader)
-	tree = Parse(&tokenizer)
-	object, ok = tree.(ObjectNode)
+	tree, _ = Parse(&tokenizer)
+	object, ok = tree.(*ObjectNode)
 	if !ok {
 		panic("Should have been an object node")
 	} else if len(object.properties) != 2 {
 		panic("Should have had at least one element as property")
-	} else if object.properties[0].name != "\"f\"" {
-		panic(fmt.Sprintf("The name of the property should have been \"f\", but was \"%s\"", object.properties[0].name))
+	} else if object.properties[0].key.Content != "\"f\"" {
+		panic(fmt.Sprintf("The name of the property should have been \"f\", but was \"%s\"", object.properties[0].key.Content))
 	} else {
-		v, ok := (*object.properties[0].value).(ValueNode)
+		v, ok := object.properties[0].value.(*ValueNode)
 		if !ok {
 			panic("The type of the property should have been a value node")
 		} else if v.token.Content != "1" {
@@ -44,8 +44,8 @@ func TestParse(t *testing.T) {
 
 	reader = s.Init(strings.NewReader("[1,2,3]"))
 	tokenizer = NewTokenizer(reader)
-	tree = Parse(&tokenizer)
-	arr, ok = tree.(ArrayNode)
+	tree, _ = Parse(&tokenizer)
+	arr, ok = tree.(*ArrayNode)
 	if !ok {
 		panic("Should have parsed as an array node")
 	} else if len(arr.elements) != 3 {
@@ -63,14 +63,14 @@ func TestParse(t *testing.T) {
 
 	reader = s.Init(strings.NewReader("[{}, 2]"))
 	tokenizer = NewTokenizer(reader)
-	tree = Parse(&tokenizer)
-	arr, ok = tree.(ArrayNode)
+	tree, _ = Parse(&tokenizer)
+	arr, ok = tree.(*ArrayNode)
 	if !ok {
 		panic("Should have parsed as an array node")
 	} else if len(arr.elements) != 2 {
 		panic("Should have had 1 elements")
 	} else {
-		_, ok := (*arr.elements[0]).(ObjectNode)
+		_, ok := arr.elements[0].(*ObjectNode)
 		if !ok {
 			panic("The first element should have been an object node")
 		}
@@ -78,30 +78,30 @@ func TestParse(t *testing.T) {
 
 	reader = s.Init(strings.NewReader("{} "))
 	tokenizer = NewTokenizer(reader)
-	tree = Parse(&tokenizer)
-	_, ok = tree.(ObjectNode)
+	tree, _ = Parse(&tokenizer)
+	_, ok = tree.(*ObjectNode)
 	if !ok {
 		panic("Should have been parsed as an object")
 	}
 
 	reader = s.Init(strings.NewReader("{\"f\":{\"g\":\"1\"}}"))
 	tokenizer = NewTokenizer(reader)
-	tree = Parse(&tokenizer)
-	object, ok = tree.(ObjectNode)
+	tree, _ = Parse(&tokenizer)
+	object, ok = tree.(*ObjectNode)
 	if !ok {
 		panic("Should have been an object node")
 	} else if len(object.properties) != 1 {
 		panic("Should have had exactly one element as property")
-	} else if object.properties[0].name != "\"f\"" {
-		panic(fmt.Sprintf("The name of the property should have been \"f\", but was \"%s\"", object.properties[0].name))
+	} else if object.properties[0].key.Content != "\"f\"" {
+		panic(fmt.Sprintf("The name of the property should have been \"f\", but was \"%s\"", object.properties[0].key.Content))
 	} else {
-		o, ok := (*object.properties[0].value).(ObjectNode)
+		o, ok := object.properties[0].value.(*ObjectNode)
 		if !ok {
 			panic("The type of the property should have been an object node")
 		} else if len(o.properties) != 1 {
 			panic("The sub object should have exactly one property")
 		} else {
-			v, ok := (*o.properties[0].value).(ValueNode)
+			v, ok := o.properties[0].value.(*ValueNode)
 			if !ok {
 				panic("The value should have been a value node")
 			} else if v.token.Content != "\"1\"" {
@@ -110,3 +110,140 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+// parseInput parses input with a tokenizer of its own
+func parseInput(input string) (Node, error) {
+	var s scanner.Scanner
+	tokenizer := NewTokenizer(s.Init(strings.NewReader(input)))
+	return Parse(&tokenizer)
+}
+
+// parseString parses input, which must be valid
+func parseString(input string) Node {
+	tree, err := parseInput(input)
+	assert(err == nil, fmt.Sprintf("Should have parsed %s, but got %v", input, err))
+	return tree
+}
+
+func parseError(input string) *SyntaxError {
+	tree, err := parseInput(input)
+	assert(tree == nil, fmt.Sprintf("Should not have returned a tree for %s", input))
+	syntaxError, ok := err.(*SyntaxError)
+	assert(ok, fmt.Sprintf("Should have returned a syntax error for %s, but got %v", input, err))
+	return syntaxError
+}
+
+func TestParseErrors(t *testing.T) {
+	err := parseError("{\"f\" 1}")
+	assert(err.Position.Line == 1 && err.Position.Column == 6, fmt.Sprintf("Wrong position %s", err.Position))
+	assert(len(err.Expected) == 1 && err.Expected[0] == JSONColon, "Should have expected a colon")
+	assert(err.Found.Content == "1", "Should have found the number 1")
+	assert(err.Line == "{\"f\" 1", fmt.Sprintf("Wrong snippet %q", err.Line))
+
+	err = parseError("[1,\n  2\n  3]")
+	assert(err.Position.Line == 3 && err.Position.Column == 3, fmt.Sprintf("Wrong position %s", err.Position))
+	assert(err.Snippet() == "  3\n  ^", fmt.Sprintf("Wrong snippet %q", err.Snippet()))
+
+	err = parseError("[1, 2")
+	assert(err.Found.TokenType == JSONEnd, "Should have found the end of the input")
+
+	err = parseError("{1: 2}")
+	assert(err.Expected[0] == JSONString, "Keys should have to be strings")
+
+	err = parseError("{} {}")
+	assert(err.Position.Column == 4, "Should have rejected the trailing value")
+
+	err = parseError("[\"abc")
+	assert(err.Msg == "unterminated string", fmt.Sprintf("Wrong message %q", err.Msg))
+
+	err = parseError("[1, #]")
+	assert(err.Found.Content == "#", "Should have found the bad character")
+	assert(err.Position.Column == 5, fmt.Sprintf("Wrong position %s", err.Position))
+
+	// Long lines, such as those of minified JSON, are quoted around the error
+	err = parseError("[" + strings.Repeat("1,", 1000) + "}")
+	assert(err.Position.Column == 2002, fmt.Sprintf("Wrong position %s", err.Position))
+	expected := "..." + strings.Repeat("1,", 20) + "}\n" + strings.Repeat(" ", 43) + "^"
+	assert(err.Snippet() == expected, fmt.Sprintf("Wrong snippet %q", err.Snippet()))
+}
+
+func TestParseTolerant(t *testing.T) {
+	var s scanner.Scanner
+	var tokenizer Tokenizer
+	var tree Node
+	var errs ErrorList
+
+	tokenizer = NewTokenizer(s.Init(strings.NewReader("{\"a\": 1, \"b\" 2, \"c\": [1,, 3, #], \"d\": 4}")))
+	tree, errs = ParseTolerant(&tokenizer)
+	assert(len(errs) == 3, fmt.Sprintf("Should have found 3 errors, but found %d: %v", len(errs), errs))
+	assert(errs[0].Expected[0] == JSONColon, "The first error should be the missing colon")
+	assert(errs[1].Found.Content == ",", "The second error should be the missing element")
+	assert(errs[2].Found.Content == "#", "The third error should be the bad character")
+	object, ok := tree.(*ObjectNode)
+	assert(ok, "Should have been an object node")
+	assert(len(object.properties) == 3, fmt.Sprintf("Should have kept 3 properties, but kept %d", len(object.properties)))
+	arr, ok := object.properties[1].value.(*ArrayNode)
+	assert(ok, "The second property should have been an array node")
+	assert(len(arr.elements) == 2, fmt.Sprintf("Should have kept 2 elements, but kept %d", len(arr.elements)))
+	assert(object.properties[2].key.Content == "\"d\"", "Should have carried on after the array")
+
+	tokenizer = NewTokenizer(s.Init(strings.NewReader("[{\"a\": 1]")))
+	tree, errs = ParseTolerant(&tokenizer)
+	assert(len(errs) == 1, fmt.Sprintf("Should have found 1 error, but found %d: %v", len(errs), errs))
+	arr, ok = tree.(*ArrayNode)
+	assert(ok, "Should have been an array node")
+	assert(len(arr.elements) == 1, "The mismatched bracket should have closed the array")
+
+	tokenizer = NewTokenizer(s.Init(strings.NewReader("{\"a\": [1, 2")))
+	tree, errs = ParseTolerant(&tokenizer)
+	assert(len(errs) == 1, fmt.Sprintf("Should have found 1 error, but found %d: %v", len(errs), errs))
+	assert(errs[0].Found.TokenType == JSONEnd, "Should have run into the end of the input")
+	_, ok = tree.(*ObjectNode)
+	assert(ok, "Should have been an object node")
+
+	tokenizer = NewTokenizer(s.Init(strings.NewReader("[1, 2]")))
+	_, errs = ParseTolerant(&tokenizer)
+	assert(errs.Err() == nil, "Should not have found any errors")
+}
+
+func TestParseDepth(t *testing.T) {
+	parseString(strings.Repeat("[", maxDepth) + strings.Repeat("]", maxDepth))
+
+	err := parseError(strings.Repeat("[", maxDepth) + "{" + strings.Repeat("]", maxDepth))
+	assert(err.Position.Column == maxDepth+1, fmt.Sprintf("Should have stopped at the object, not %s", err.Position))
+	assert(err.Found.TokenType == JSONOpenBrace, "Should have found the brace that went too deep")
+
+	// Far deeper input is rejected as soon as it goes too deep, rather than
+	// running out of stack
+	err = parseError(strings.Repeat("[", 5000000))
+	assert(err.Position.Column == maxDepth+1, fmt.Sprintf("Wrong position %s", err.Position))
+
+	var s scanner.Scanner
+	tokenizer := NewTokenizer(s.Init(strings.NewReader(strings.Repeat("[", 5000000))))
+	tree, errs := ParseTolerant(&tokenizer)
+	assert(tree == nil && len(errs) == 1, fmt.Sprintf("Should have given up with 1 error, but found %d: %v", len(errs), errs))
+}
+
+func TestNodeAccessors(t *testing.T) {
+	tree := parseString("{\"a\\u0062\": [1, true],\n \"c\": null}")
+	assert(tree.Kind() == ObjectKind, fmt.Sprintf("Should have been an object, but was %s", tree.Kind()))
+	assert(tree.Pos().Offset == 0 && tree.End().Offset == 34, fmt.Sprintf("Wrong span %d-%d", tree.Pos().Offset, tree.End().Offset))
+
+	properties := tree.(*ObjectNode).Properties()
+	assert(len(properties) == 2, "Should have had 2 properties")
+	assert(properties[0].Key() == "ab", fmt.Sprintf("The key should have been decoded, but was %s", properties[0].Key()))
+	assert(properties[0].KeyToken().Content == "\"a\\u0062\"", "The key token should have been kept as is")
+	assert(properties[0].Kind() == PropertyKind, "Should have been a property")
+	assert(properties[0].Pos().Offset == 1 && properties[0].End().Offset == 21, "Wrong span for the property")
+
+	elements := properties[0].Value().(*ArrayNode).Elements()
+	assert(len(elements) == 2, "Should have had 2 elements")
+	assert(elements[0].Kind() == NumberKind, "The first element should have been a number")
+	assert(elements[1].Kind() == BoolKind, "The second element should have been a bool")
+	assert(elements[1].(*ValueNode).Token().Content == "true", "The second element should have been true")
+
+	value := properties[1].Value()
+	assert(value.Kind() == NullKind, "The second property should have been null")
+	assert(value.Pos().Line == 2 && value.Pos().Column == 7, fmt.Sprintf("Wrong position %s", value.Pos()))
+	assert(value.End().Column == 11, fmt.Sprintf("Wrong end %s", value.End()))
+}
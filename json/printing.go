@@ -1,22 +1,17 @@
 package json
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/scanner"
 	"unicode/utf8"
 )
 
-var colorMap = map[int]string{
-	JSONIdentifier:         "b58900",
-	JSONString:             "2aa198",
-	JSONNumber:             "d33682",
-	JSONOpenBrace:          "93a1a1",
-	JSONCloseBrace:         "93a1a1",
-	JSONColon:              "268bd2",
-	JSONComma:              "859900",
-	JSONOpenSquareBracket:  "6c71c4",
-	JSONCloseSquareBracket: "6c71c4",
-}
-
 func spacePad(n int) string {
 	var str string
 	for i := 0; i < n; i++ {
@@ -25,12 +20,222 @@ func spacePad(n int) string {
 	return str
 }
 
-func printSpan(content, color string, spaces int) {
-	fmt.Printf("%s<span style='color:#%s'>", spacePad(spaces), color)
+// Format is the markup that a Printer wraps tokens in
+type Format int
+
+const (
+	// FormatHTML wraps every token in a coloured HTML span
+	FormatHTML Format = iota
+	// FormatText writes plain JSON, without any markup
+	FormatText
+	// FormatANSI colours tokens with ANSI escape sequences, for terminals
+	FormatANSI
+)
+
+// PrinterOptions controls the layout of printed trees
+type PrinterOptions struct {
+	// Format is the markup to write
+	Format Format
+	// Colors is the range of colours to use with FormatANSI
+	Colors ColorMode
+	// Theme is the colours to highlight tokens with
+	Theme Theme
+	// CSSClasses makes FormatHTML mark tokens with the classes of Stylesheet,
+	// such as j-str, rather than with inline colours
+	CSSClasses bool
+	// Collapsible makes FormatHTML wrap objects and arrays in folds, which hide
+	// their members when their opening brace or bracket is clicked. The page
+	// needs FoldStylesheet and FoldScript for them to work.
+	Collapsible bool
+	// CollapseDepth, if positive, starts the folds nested deeper than it off
+	// folded. The root is at depth 1.
+	CollapseDepth int
+	// LineNumbers makes FormatHTML start every line with its number, in a
+	// gutter that AnchorStylesheet lays out
+	LineNumbers bool
+	// PathAnchors makes FormatHTML give every property and element an id, its
+	// JSON Pointer, so that it can be linked to as #/widgets/2. AnchorScript
+	// copies the path of whatever is clicked.
+	PathAnchors bool
+	// Indent is written once for every level of nesting
+	Indent string
+	// AlignKeys pads the keys of an object, so that its colons line up
+	AlignKeys bool
+	// MaxKeyWidth is the longest key, in runes and quotes included, that still
+	// gets aligned. Objects with a longer key are not aligned at all.
+	MaxKeyWidth int
+	// InlineArrayMaxElements is the most elements an array of scalars can have
+	// and still be printed on a single line
+	InlineArrayMaxElements int
+	// InlineArrayMaxWidth is the widest, in runes, that an array can be and
+	// still be printed on a single line. Zero means there is no limit.
+	InlineArrayMaxWidth int
+	// TrailingCommas puts commas at the end of lines, rather than at the start
+	// of the next one
+	TrailingCommas bool
+}
+
+// DefaultPrinterOptions returns the options that the printer has always used
+func DefaultPrinterOptions() PrinterOptions {
+	return PrinterOptions{
+		Theme:                  SolarizedDark,
+		Indent:                 "  ",
+		AlignKeys:              true,
+		MaxKeyWidth:            50,
+		InlineArrayMaxElements: 10,
+	}
+}
+
+// Printer writes trees to an io.Writer, in the format of PrinterOptions.Format:
+// syntax highlighted HTML, plain text, or text coloured with ANSI escape
+// sequences. Output is buffered, and flushed at the end of every Print call.
+// Once writing fails, nothing more is written and every call returns the same
+// error.
+type Printer struct {
+	w       *bufio.Writer
+	err     error
+	options PrinterOptions
+	// depth is the number of folds that are open
+	depth int
+	// line is the number of the line being written, 0 before the first
+	line int
+}
+
+// NewPrinter returns a Printer that writes to w, laid out following options
+func NewPrinter(w io.Writer, options PrinterOptions) *Printer {
+	return &Printer{w: bufio.NewWriter(w), options: options}
+}
+
+func (p *Printer) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
+
+func (p *Printer) flush() error {
+	if p.err == nil {
+		p.err = p.w.Flush()
+	}
+	return p.err
+}
+
+// printSpan writes content highlighted as role, which is a token type,
+// keyRole or unparsedRole.
+func (p *Printer) printSpan(content string, role int) {
+	if role == unparsedRole && p.options.Format != FormatHTML {
+		content = escapeControls(content)
+	}
+	switch p.options.Format {
+	case FormatText:
+		p.printText(content)
+	case FormatANSI:
+		if p.options.Colors == ColorNone {
+			p.printText(content)
+		} else {
+			p.printf("%s%s%s", ansiColor(p.options.Theme.roleColor(role), p.options.Colors), content, ansiReset)
+		}
+	default:
+		if p.options.CSSClasses {
+			p.printf("<span class='%s'>%s</span>", className(role), p.escapeLines(content))
+		} else {
+			p.printf("<span style='color:#%s'>%s</span>", p.options.Theme.roleColor(role), p.escapeLines(content))
+		}
+	}
+}
+
+// printText writes text that is not a token, such as whitespace
+func (p *Printer) printText(text string) {
+	if p.options.Format == FormatHTML {
+		p.printf("%s", p.escapeLines(text))
+		return
+	}
+	p.printf("%s", text)
+}
+
+// escapeControls escapes the control characters in input that was not
+// tokenized, such as the escape sequences of a terminal, as \u001b and so on.
+// Tabs and line breaks are kept.
+func escapeControls(content string) string {
+	var buffer bytes.Buffer
 	for _, r := range content {
-		fmt.Printf("%s", getEscapedRune(r))
+		if (r < 0x20 && r != '\t' && r != '\n' && r != '\r') || (r >= 0x7f && r <= 0x9f) {
+			fmt.Fprintf(&buffer, "\\u%04x", r)
+		} else {
+			buffer.WriteRune(r)
+		}
+	}
+	return buffer.String()
+}
+
+// printInvalid writes the token that the error message was found at
+func (p *Printer) printInvalid(content string, message string) {
+	if p.options.Format != FormatHTML {
+		content = escapeControls(content)
+	}
+	switch p.options.Format {
+	case FormatText:
+		p.printText(content)
+		return
+	case FormatANSI:
+		if p.options.Colors != ColorNone {
+			if content == "" {
+				content = " "
+			}
+			p.printf("%s%s%s%s", ansiColor(p.options.Theme.Error, p.options.Colors), ansiUnderline, content, ansiReset)
+		} else {
+			p.printText(content)
+		}
+		return
+	}
+	if content == "" {
+		// There is nothing to underline at the end of the input
+		content = " "
+	}
+	if p.options.CSSClasses {
+		p.printf("<span class='%s' title='%s'>%s</span>", className(JSONInvalid), escape(message), escape(content))
+		return
+	}
+	p.printf("<span style='color:#%s; text-decoration:underline wavy' title='%s'>%s</span>",
+		p.options.Theme.Error, escape(message), escape(content))
+}
+
+// startLines writes the number of the first line, if nothing was written yet
+func (p *Printer) startLines() {
+	if p.options.Format == FormatHTML && p.options.LineNumbers && p.line == 0 {
+		p.line = 1
+		p.printf("%s", gutter(p.line))
+	}
+}
+
+func gutter(line int) string {
+	return fmt.Sprintf("<span class='j-line' data-line='%d'></span>", line)
+}
+
+// escapeLines escapes content like escape, and starts every line after the
+// first with its number if LineNumbers is set.
+func (p *Printer) escapeLines(content string) string {
+	if !p.options.LineNumbers || !strings.ContainsRune(content, '\n') {
+		return escape(content)
+	}
+	var buffer bytes.Buffer
+	for i, line := range strings.Split(content, "\n") {
+		if i > 0 {
+			p.line++
+			buffer.WriteString("\n")
+			buffer.WriteString(gutter(p.line))
+		}
+		buffer.WriteString(escape(line))
+	}
+	return buffer.String()
+}
+
+func escape(content string) string {
+	var buffer bytes.Buffer
+	for _, r := range content {
+		buffer.WriteString(getEscapedRune(r))
 	}
-	fmt.Printf("</span>")
+	return buffer.String()
 }
 
 func getEscapedRune(r rune) string {
@@ -49,129 +254,316 @@ func getEscapedRune(r rune) string {
 	return string(r)
 }
 
-func getObjectPadding(node ObjectNode) int {
-	if len(node.properties) < 1 {
+func (p *Printer) getObjectPadding(node *ObjectNode) int {
+	if !p.options.AlignKeys {
 		return 0
 	}
 	var max int
 	for _, property := range node.properties {
-		if utf8.RuneCountInString(property.name) > max {
-			max = len(property.name)
+		if width := utf8.RuneCountInString(property.key.Content); width > max {
+			max = width
 		}
-		if max > 50 {
+		if max > p.options.MaxKeyWidth {
 			return 0
 		}
 	}
 	return max
 }
 
-func shouldSamelineArray(node ArrayNode) bool {
-	if len(node.elements) > 10 {
+func (p *Printer) shouldSamelineArray(node *ArrayNode) bool {
+	if len(node.elements) > p.options.InlineArrayMaxElements {
 		return false
 	}
-	for _, el := range node.elements {
-		if _, ok := (*el).(ValueNode); !ok {
+	// The brackets, and the spaces inside them
+	width := 4
+	for i, el := range node.elements {
+		value, ok := el.(*ValueNode)
+		if !ok {
 			return false
 		}
+		if i > 0 {
+			width += 2
+		}
+		width += utf8.RuneCountInString(value.token.Content)
 	}
-	return true
+	return p.options.InlineArrayMaxWidth <= 0 || width <= p.options.InlineArrayMaxWidth
 }
 
-func printScalar(token Token) {
+func (p *Printer) printScalar(token Token) {
 	switch token.TokenType {
-	case JSONIdentifier:
-		printSpan(token.Content, colorMap[JSONIdentifier], 0)
-	case JSONString:
-		printSpan(token.Content, colorMap[JSONString], 0)
-	case JSONNumber:
-		printSpan(token.Content, colorMap[JSONNumber], 0)
+	case JSONString, JSONNumber, JSONTrue, JSONFalse, JSONNull:
+		p.printSpan(token.Content, token.TokenType)
 	default:
-		print("I don't know what kind of value this is")
-	}
-}
-
-func printObject(node ObjectNode, indent int) {
-	padding := indent * 2
-
-	printSpan("{", colorMap[JSONOpenBrace], 0)
-	fmt.Println("")
-	oPaddingNum := getObjectPadding(node)
-	if len(node.properties) > 0 {
-		property := node.properties[0]
-		printSpan(property.name, colorMap[JSONString], padding+4)
-		if oPaddingNum < 50 {
-			propertyPad := spacePad(oPaddingNum - utf8.RuneCountInString(property.name))
-			fmt.Printf("%s", propertyPad)
-		}
-		printSpan(":", colorMap[JSONColon], 0)
-		fmt.Printf(" ")
-		PrintTree(*property.value, indent+1)
-		for _, property := range node.properties[1:] {
-			fmt.Println("")
-			printSpan(",", colorMap[JSONComma], padding+2)
-			fmt.Printf(" ")
-			printSpan(property.name, colorMap[JSONString], 0)
-			if oPaddingNum < 50 {
-				propertyPad := spacePad(oPaddingNum - utf8.RuneCountInString(property.name))
-				fmt.Printf("%s", propertyPad)
-			}
-			printSpan(":", colorMap[JSONColon], 0)
-			fmt.Printf(" ")
-			PrintTree(*property.value, indent+1)
-		}
-	}
-	fmt.Println("")
-	printSpan("}", colorMap[JSONCloseBrace], padding)
-}
-
-func printArray(node ArrayNode, indent int) {
-	padding := indent * 2
-
-	printSpan("[", colorMap[JSONOpenSquareBracket], 0)
-	if len(node.elements) > 0 {
-		if shouldSamelineArray(node) {
-			fmt.Printf(" ")
-			for _, element := range node.elements[:len(node.elements)-1] {
-				if node, ok := (*element).(ValueNode); ok {
-					printScalar(node.token)
-					printSpan(",", colorMap[JSONComma], 0)
-					fmt.Printf(" ")
-				} else {
-					panic("Weird. This should have been a value node")
-				}
+		if p.err == nil {
+			p.err = fmt.Errorf("can not print %s as a value", tokenName(token.TokenType))
+		}
+	}
+}
+
+func (p *Printer) indentation(indent int) string {
+	return strings.Repeat(p.options.Indent, indent)
+}
+
+// beginMember starts a new line for a property or an element of a container
+// nested indent levels deep.
+func (p *Printer) beginMember(first bool, indent int) {
+	p.printText("\n" + p.indentation(indent+1))
+	if p.options.TrailingCommas {
+		return
+	}
+	// Leading commas are followed by a space, which the first member lines up with
+	if first {
+		p.printf("  ")
+	} else {
+		p.printSpan(",", JSONComma)
+		p.printf(" ")
+	}
+}
+
+// endMember finishes the line of a property or an element of a container
+func (p *Printer) endMember(last bool) {
+	if p.options.TrailingCommas && !last {
+		p.printSpan(",", JSONComma)
+	}
+}
+
+// printOpening writes the opening brace or bracket of a container. With
+// Collapsible set, a container with members also starts a fold.
+func (p *Printer) printOpening(content string, role int, members int) {
+	if p.options.Format != FormatHTML || !p.options.Collapsible || members == 0 {
+		p.printSpan(content, role)
+		return
+	}
+	p.depth++
+	class := "j-fold"
+	if p.options.CollapseDepth > 0 && p.depth > p.options.CollapseDepth {
+		class += " j-folded"
+	}
+	p.printf("<span class='%s' data-depth='%d'><span class='j-toggle'>", class, p.depth)
+	p.printSpan(content, role)
+	p.printf("</span><span class='j-body'>")
+}
+
+// printClosing writes the closing brace or bracket of a container, ending the
+// fold that printOpening started. A folded container shows how many members it
+// has instead.
+func (p *Printer) printClosing(content string, role int, members int, noun, nouns string) {
+	if p.options.Format != FormatHTML || !p.options.Collapsible || members == 0 {
+		p.printSpan(content, role)
+		return
+	}
+	if members != 1 {
+		noun = nouns
+	}
+	p.printf("</span><span class='j-summary'> &hellip; %d %s </span>", members, noun)
+	p.printSpan(content, role)
+	p.printf("</span>")
+	p.depth--
+}
+
+// beginAnchor starts the property or element at path, which PathAnchors makes
+// a link target.
+func (p *Printer) beginAnchor(path Path) {
+	if p.options.Format == FormatHTML && p.options.PathAnchors {
+		pointer := escape(path.String())
+		p.printf("<span class='j-member' id='%s' title='%s'>", pointer, pointer)
+	}
+}
+
+// endAnchor ends the property or element that beginAnchor started
+func (p *Printer) endAnchor() {
+	if p.options.Format == FormatHTML && p.options.PathAnchors {
+		p.printf("</span>")
+	}
+}
+
+func (p *Printer) printObject(node *ObjectNode, indent int, path Path) {
+	p.printOpening("{", JSONOpenBrace, len(node.properties))
+	oPaddingNum := p.getObjectPadding(node)
+	for i, property := range node.properties {
+		p.beginMember(i == 0, indent)
+		p.beginAnchor(path.child(property.Key()))
+		p.printSpan(property.key.Content, keyRole)
+		if oPaddingNum > 0 {
+			p.printf("%s", spacePad(oPaddingNum-utf8.RuneCountInString(property.key.Content)))
+		}
+		p.printSpan(":", JSONColon)
+		p.printf(" ")
+		p.printTree(property.value, indent+1, path.child(property.Key()))
+		p.endAnchor()
+		p.endMember(i == len(node.properties)-1)
+	}
+	p.printText("\n" + p.indentation(indent))
+	p.printClosing("}", JSONCloseBrace, len(node.properties), "property", "properties")
+}
+
+func (p *Printer) printArray(node *ArrayNode, indent int, path Path) {
+	if len(node.elements) == 0 {
+		p.printSpan("[", JSONOpenSquareBracket)
+		p.printSpan("]", JSONCloseSquareBracket)
+		return
+	}
+	// Arrays short enough to fit on one line are not worth folding
+	if p.shouldSamelineArray(node) {
+		p.printSpan("[", JSONOpenSquareBracket)
+		p.printf(" ")
+		for i, element := range node.elements {
+			if i > 0 {
+				p.printSpan(",", JSONComma)
+				p.printf(" ")
 			}
-			if node, ok := (*node.elements[len(node.elements)-1]).(ValueNode); ok {
-				printScalar(node.token)
+			p.beginAnchor(path.child(strconv.Itoa(i)))
+			p.printScalar(element.(*ValueNode).token)
+			p.endAnchor()
+		}
+		p.printf(" ")
+		p.printSpan("]", JSONCloseSquareBracket)
+		return
+	}
+	p.printOpening("[", JSONOpenSquareBracket, len(node.elements))
+	for i, element := range node.elements {
+		p.beginMember(i == 0, indent)
+		p.beginAnchor(path.child(strconv.Itoa(i)))
+		p.printTree(element, indent+1, path.child(strconv.Itoa(i)))
+		p.endAnchor()
+		p.endMember(i == len(node.elements)-1)
+	}
+	p.printText("\n" + p.indentation(indent))
+	p.printClosing("]", JSONCloseSquareBracket, len(node.elements), "element", "elements")
+}
+
+func (p *Printer) printTree(tree Node, indent int, path Path) {
+	if node, ok := tree.(*ObjectNode); ok {
+		p.printObject(node, indent, path)
+	} else if node, ok := tree.(*ArrayNode); ok {
+		p.printArray(node, indent, path)
+	} else if node, ok := tree.(*ValueNode); ok {
+		p.printScalar(node.token)
+	} else if p.err == nil {
+		p.err = fmt.Errorf("can not print %T", tree)
+	}
+}
+
+// PrintTree writes the tree, as if it were nested indent levels deep.
+func (p *Printer) PrintTree(tree Node, indent int) error {
+	p.startLines()
+	p.printTree(tree, indent, Path{})
+	return p.flush()
+}
+
+// PrintTree prints the tree to standard output, with the default options
+func PrintTree(tree Node, indent int) error {
+	return NewPrinter(os.Stdout, DefaultPrinterOptions()).PrintTree(tree, indent)
+}
+
+// clampOffset keeps an offset taken from a token within the bounds of source.
+func clampOffset(source string, offset int) int {
+	if offset < 0 {
+		return 0
+	} else if offset > len(source) {
+		return len(source)
+	}
+	return offset
+}
+
+// PrintError writes source, a document that failed to parse with err, as it
+// is. In HTML, everything before the error is highlighted as usual, the
+// offending token is underlined with the error as its tooltip, and the rest of
+// the document is left unhighlighted.
+func (p *Printer) PrintError(source string, err *SyntaxError) error {
+	var s scanner.Scanner
+	tokenizer := NewTokenizer(s.Init(strings.NewReader(source)))
+	// Errors inside a token, such as a bad escape sequence, are positioned where
+	// the problem is, but the whole token is marked
+	errorOffset := err.Position.Offset
+	if err.Found.Position.IsValid() {
+		errorOffset = err.Found.Position.Offset
+	}
+	errorOffset = clampOffset(source, errorOffset)
+	p.startLines()
+
+	offset := 0
+	for {
+		token, _ := tokenizer.Scan()
+		if token.TokenType == JSONEnd || token.TokenType == JSONInvalid || token.Position.Offset >= errorOffset {
+			break
+		}
+		p.printText(source[offset:token.Position.Offset])
+		p.printSpan(token.Content, token.TokenType)
+		offset = clampOffset(source, token.End.Offset)
+	}
+	if offset < errorOffset {
+		p.printText(source[offset:errorOffset])
+	}
+	p.printInvalid(err.Found.Content, err.Error())
+
+	rest := source[clampOffset(source, err.Found.End.Offset):]
+	if rest != "" {
+		p.printSpan(rest, unparsedRole)
+	}
+	return p.flush()
+}
+
+// PrintHighlighted writes source exactly as it is, rather than laying it out
+// again, with its tokens highlighted. Keys are told apart from other strings by
+// the colon after them. Source does not need to be valid JSON: tokens that
+// are invalid on their own are marked as errors, and the rest is highlighted
+// as usual.
+func (p *Printer) PrintHighlighted(source string) error {
+	var s scanner.Scanner
+	tokenizer := NewLosslessTokenizer(s.Init(strings.NewReader(source)))
+	var tokens []Token
+	// The errors of the invalid tokens, by index, as Err only has the latest
+	messages := map[int]string{}
+	for {
+		token, _ := tokenizer.Scan()
+		if token.TokenType == JSONEnd {
+			break
+		}
+		if token.TokenType == JSONInvalid {
+			messages[len(tokens)] = tokenizer.err.Msg
+		}
+		tokens = append(tokens, token)
+	}
+
+	p.startLines()
+	offset := 0
+	for i, token := range tokens {
+		start, end := clampOffset(source, token.Position.Offset), clampOffset(source, token.End.Offset)
+		if offset < start {
+			p.printText(source[offset:start])
+		}
+		// Take the text from source, in case a token does not hold all of it
+		content := source[start:end]
+		switch token.TokenType {
+		case JSONWhitespace:
+			p.printText(content)
+		case JSONInvalid:
+			p.printInvalid(content, messages[i])
+		case JSONString:
+			if isKey(tokens[i+1:]) {
+				p.printSpan(content, keyRole)
 			} else {
-				panic("Weird. This should have been a value node")
-			}
-			fmt.Printf(" ")
-			printSpan("]", colorMap[JSONCloseSquareBracket], 0)
-		} else {
-			fmt.Println("")
-			fmt.Printf("%s", spacePad(padding+4))
-			element := node.elements[0]
-			PrintTree(*element, indent+1)
-			for _, element := range node.elements[1:] {
-				fmt.Println("")
-				printSpan(",", colorMap[JSONComma], padding+2)
-				fmt.Printf(" ")
-				PrintTree(*element, indent+1)
+				p.printSpan(content, JSONString)
 			}
-			fmt.Println("")
-			printSpan("]", colorMap[JSONCloseSquareBracket], padding)
+		default:
+			p.printSpan(content, token.TokenType)
 		}
+		offset = end
 	}
+	if offset < len(source) {
+		p.printText(source[offset:])
+	}
+	return p.flush()
 }
 
-func PrintTree(tree Node, indent int) {
-	if node, ok := tree.(ObjectNode); ok {
-		printObject(node, indent)
-	} else if node, ok := tree.(ArrayNode); ok {
-		printArray(node, indent)
-	} else if node, ok := tree.(ValueNode); ok {
-		printScalar(node.token)
-	} else {
-		panic("I don't know what kind of a node this is")
+// isKey reports whether a string followed by the given tokens is the key of a
+// property.
+func isKey(following []Token) bool {
+	for _, token := range following {
+		if token.TokenType != JSONWhitespace {
+			return token.TokenType == JSONColon
+		}
 	}
+	return false
 }
@@ -1,6 +1,15 @@
 package json
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"text/scanner"
+)
 
 func TestGetEscapedRune(t *testing.T) {
 	assert(getEscapedRune('<') == "&lt;", "Should have escaped '<' to '&lt;'")
@@ -10,3 +19,183 @@ func TestGetEscapedRune(t *testing.T) {
 	assert(getEscapedRune('\'') == "&apos;", "Should have escaped ''' to '&apos'")
 	assert(getEscapedRune('f') == "f", "Should not have escaped 'f'")
 }
+
+// printString parses input and prints it in the format of the options
+func printString(input string, options PrinterOptions) string {
+	var buffer bytes.Buffer
+	err := NewPrinter(&buffer, options).PrintTree(parseString(input), 0)
+	assert(err == nil, fmt.Sprintf("Should have printed %s, but got %v", input, err))
+	return buffer.String()
+}
+
+var tagPattern = regexp.MustCompile("<[^>]*>")
+
+// printLayout prints the input, and strips the markup to leave only the layout
+func printLayout(input string, options PrinterOptions) string {
+	return strings.Replace(tagPattern.ReplaceAllString(printString(input, options), ""), "&quot;", "\"", -1)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestPrinter(t *testing.T) {
+	output := printString("{\"a\":[1,2]}", DefaultPrinterOptions())
+	expected := "<span style='color:#93a1a1'>{</span>\n" +
+		"    <span style='color:#2aa198'>&quot;a&quot;</span><span style='color:#268bd2'>:</span> " +
+		"<span style='color:#6c71c4'>[</span> <span style='color:#d33682'>1</span><span style='color:#859900'>,</span> " +
+		"<span style='color:#d33682'>2</span> <span style='color:#6c71c4'>]</span>\n" +
+		"<span style='color:#93a1a1'>}</span>"
+	assert(output == expected, fmt.Sprintf("Wrong output:\n%s", output))
+
+	err := NewPrinter(failingWriter{}, DefaultPrinterOptions()).PrintTree(NewNull(), 0)
+	assert(err != nil && err.Error() == "disk full", fmt.Sprintf("Should have returned the write error, but got %v", err))
+
+	var buffer bytes.Buffer
+	err = NewPrinter(&buffer, DefaultPrinterOptions()).PrintError("[1, }", &SyntaxError{Msg: "bad", Position: scanner.Position{Offset: 4, Line: 1, Column: 5}, Found: Token{Content: "}", Position: scanner.Position{Offset: 4, Line: 1, Column: 5}, End: scanner.Position{Offset: 5}}})
+	assert(err == nil, fmt.Sprintf("Should have printed the error, but got %v", err))
+	assert(strings.Contains(buffer.String(), "title='&lt;input&gt;:1:5: bad'>}</span>"), fmt.Sprintf("Should have marked the error:\n%s", buffer.String()))
+}
+
+func TestPrintErrorInsideToken(t *testing.T) {
+	// These errors are positioned inside the token they are in, which is still
+	// printed only once
+	options := DefaultPrinterOptions()
+	options.Format = FormatText
+	for _, input := range []string{"[01]", `["ab\x"]`, "[-x]", "{\"a\": \"abc\n}"} {
+		var buffer bytes.Buffer
+		err := NewPrinter(&buffer, options).PrintError(input, parseError(input))
+		assert(err == nil, fmt.Sprintf("Should have printed %q, but got %v", input, err))
+		assert(buffer.String() == input, fmt.Sprintf("Expected %q to be printed as it is, but got %q", input, buffer.String()))
+	}
+}
+
+func TestPrintErrorControls(t *testing.T) {
+	// Control characters in input that was not tokenized are escaped, so that
+	// they can not reach a terminal
+	input := "[1, \x1b[2J\x07]\n\t\u009b"
+	options := DefaultPrinterOptions()
+	options.Format = FormatANSI
+	options.Colors = ColorNone
+	var buffer bytes.Buffer
+	err := NewPrinter(&buffer, options).PrintError(input, parseError(input))
+	assert(err == nil, fmt.Sprintf("Should have printed the error, but got %v", err))
+	expected := "[1, \\u001b[2J\\u0007]\n\t\\u009b"
+	assert(buffer.String() == expected, fmt.Sprintf("Expected %q, but got %q", expected, buffer.String()))
+
+	buffer.Reset()
+	options.Format = FormatText
+	err = NewPrinter(&buffer, options).PrintHighlighted(input)
+	assert(err == nil && buffer.String() == expected, fmt.Sprintf("Expected %q, but got %q", expected, buffer.String()))
+}
+
+func TestPrinterOptions(t *testing.T) {
+	input := "{\"a\": [1, 2, 3], \"bcd\": {\"e\": []}}"
+
+	output := printLayout(input, DefaultPrinterOptions())
+	expected := "{\n    \"a\"  : [ 1, 2, 3 ]\n  , \"bcd\": {\n      \"e\": []\n  }\n}"
+	assert(output == expected, fmt.Sprintf("Wrong default layout:\n%s", output))
+
+	options := DefaultPrinterOptions()
+	options.Indent = "\t"
+	options.TrailingCommas = true
+	options.AlignKeys = false
+	options.InlineArrayMaxElements = 2
+	output = printLayout(input, options)
+	expected = "{\n\t\"a\": [\n\t\t1,\n\t\t2,\n\t\t3\n\t],\n\t\"bcd\": {\n\t\t\"e\": []\n\t}\n}"
+	assert(output == expected, fmt.Sprintf("Wrong layout with trailing commas:\n%s", output))
+
+	options = DefaultPrinterOptions()
+	options.MaxKeyWidth = 4
+	options.InlineArrayMaxWidth = 10
+	output = printLayout(input, options)
+	expected = "{\n    \"a\": [\n      1\n    , 2\n    , 3\n  ]\n  , \"bcd\": {\n      \"e\": []\n  }\n}"
+	assert(output == expected, fmt.Sprintf("Wrong layout with narrow limits:\n%s", output))
+}
+
+func TestPrinterText(t *testing.T) {
+	input := "{\"a\": [1, \"<&>\", {\"b\": null}], \"c\\\"d\": {}, \"e\": [], \"f\": [[true], -1.5e3]}"
+	expected, _ := ToInterface(parseString(input))
+
+	for _, trailing := range []bool{false, true} {
+		options := DefaultPrinterOptions()
+		options.Format = FormatText
+		options.TrailingCommas = trailing
+		output := printString(input, options)
+		assert(!strings.Contains(output, "<span") && strings.Contains(output, "<&>"), fmt.Sprintf("Should not have written any markup:\n%s", output))
+
+		reparsed, err := parseInput(output)
+		assert(err == nil, fmt.Sprintf("The output should have been valid JSON, but got %v:\n%s", err, output))
+		actual, _ := ToInterface(reparsed)
+		assert(reflect.DeepEqual(actual, expected), fmt.Sprintf("The output should have round tripped:\n%s", output))
+	}
+}
+
+func TestPrinterCollapsible(t *testing.T) {
+	options := DefaultPrinterOptions()
+	options.Collapsible = true
+	options.CollapseDepth = 1
+	output := printString(`{"a": {"b": 1}, "c": [], "d": [1, 2]}`, options)
+	assert(strings.Count(output, "class='j-fold") == 2, fmt.Sprintf("Should have folded only the root and a, got %s", output))
+	assert(strings.Contains(output, "<span class='j-fold' data-depth='1'>"), "Should not have folded the root")
+	assert(strings.Contains(output, "<span class='j-fold j-folded' data-depth='2'>"), "Should have folded a, which is deeper than 1")
+	assert(strings.Contains(output, "&hellip; 3 properties"), "Should have counted the properties of the root")
+	assert(strings.Contains(output, "&hellip; 1 property"), "Should have counted the property of a")
+	assert(strings.Count(output, "<span") == strings.Count(output, "</span>"), "Should have closed every span")
+	summaries := regexp.MustCompile("<span class='j-summary'>[^<]*</span>")
+	folded := tagPattern.ReplaceAllString(summaries.ReplaceAllString(output, ""), "")
+	assert(folded == tagPattern.ReplaceAllString(printString(`{"a": {"b": 1}, "c": [], "d": [1, 2]}`, DefaultPrinterOptions()), ""),
+		fmt.Sprintf("Folds should not have changed the layout, got %s", folded))
+
+	options.Format = FormatText
+	output = printString(`{"a": {"b": 1}}`, options)
+	assert(!strings.Contains(output, "j-fold"), "Should only have folded HTML")
+}
+
+func TestPrinterAnchors(t *testing.T) {
+	options := DefaultPrinterOptions()
+	options.LineNumbers = true
+	options.PathAnchors = true
+	input := `{"a/b": [1, {"c": null}], "d": 2}`
+	output := printString(input, options)
+	for _, pointer := range []string{"/a~1b", "/a~1b/0", "/a~1b/1", "/a~1b/1/c", "/d"} {
+		assert(strings.Contains(output, fmt.Sprintf("<span class='j-member' id='%s' title='%s'>", pointer, pointer)),
+			fmt.Sprintf("Should have anchored %s, got %s", pointer, output))
+	}
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		assert(strings.HasPrefix(line, fmt.Sprintf("<span class='j-line' data-line='%d'></span>", i+1)),
+			fmt.Sprintf("Line %d should have started with its number, got %s", i+1, line))
+	}
+	assert(tagPattern.ReplaceAllString(output, "") == tagPattern.ReplaceAllString(printString(input, DefaultPrinterOptions()), ""),
+		"Line numbers and anchors should not have changed the layout")
+
+	options.Format = FormatText
+	output = printString(input, options)
+	assert(!strings.Contains(output, "<span"), "Should only have numbered and anchored HTML")
+}
+
+func TestPrintHighlighted(t *testing.T) {
+	input := "{\"a\" :[1,\r\n  tru, \"x\"],\t\"b\": 01 }\n"
+	options := DefaultPrinterOptions()
+	options.Format = FormatText
+	var buffer bytes.Buffer
+	err := NewPrinter(&buffer, options).PrintHighlighted(input)
+	assert(err == nil && buffer.String() == input, fmt.Sprintf("Should have written the input as it is, but got %q", buffer.String()))
+
+	options.CSSClasses = true
+	options.Format = FormatHTML
+	buffer.Reset()
+	err = NewPrinter(&buffer, options).PrintHighlighted(input)
+	output := buffer.String()
+	assert(err == nil, fmt.Sprintf("Should have highlighted the input, but got %v", err))
+	assert(strings.Replace(tagPattern.ReplaceAllString(output, ""), "&quot;", "\"", -1) == input,
+		fmt.Sprintf("Should not have changed the text, but got %q", output))
+	assert(strings.Count(output, "class='j-key'") == 2, "Should have told keys apart by their colons")
+	assert(strings.Contains(output, "<span class='j-str'>&quot;x&quot;</span>"), "Should have highlighted a string that is not a key")
+	assert(strings.Contains(output, "title='invalid literal &quot;tru&quot;, expected true, false or null'>tru</span>"),
+		fmt.Sprintf("Should have marked the invalid literal, got %s", output))
+	assert(strings.Contains(output, "title='leading zero in number'>01</span>"), "Should have marked the invalid number too")
+}
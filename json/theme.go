@@ -0,0 +1,330 @@
+package json
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+// Theme is a colour scheme for highlighting JSON. Colours are hex RGB, such as
+// "2aa198".
+type Theme struct {
+	Name       string `json:"name"`
+	Background string `json:"background"`
+	// Foreground is the colour of anything that is not a token
+	Foreground string `json:"foreground"`
+	// Key is the colour of the keys of objects
+	Key    string `json:"key"`
+	String string `json:"string"`
+	Number string `json:"number"`
+	True   string `json:"true"`
+	False  string `json:"false"`
+	Null   string `json:"null"`
+	// Punctuation is the colour of any of braces, brackets, colons and commas
+	// that don't have a colour of their own
+	Punctuation string `json:"punctuation"`
+	Brace       string `json:"brace"`
+	Bracket     string `json:"bracket"`
+	Colon       string `json:"colon"`
+	Comma       string `json:"comma"`
+	// Error is the colour of the token that a syntax error was found at
+	Error string `json:"error"`
+	// Unparsed is the colour of whatever follows a syntax error
+	Unparsed string `json:"unparsed"`
+}
+
+// Color returns the colour of a token type, such as JSONString
+func (t Theme) Color(tokenType int) string {
+	var color string
+	switch tokenType {
+	case JSONString:
+		return t.String
+	case JSONNumber:
+		return t.Number
+	case JSONTrue:
+		return t.True
+	case JSONFalse:
+		return t.False
+	case JSONNull:
+		return t.Null
+	case JSONInvalid:
+		return t.Error
+	case JSONOpenBrace, JSONCloseBrace:
+		color = t.Brace
+	case JSONOpenSquareBracket, JSONCloseSquareBracket:
+		color = t.Bracket
+	case JSONColon:
+		color = t.Colon
+	case JSONComma:
+		color = t.Comma
+	default:
+		return t.Foreground
+	}
+	if color == "" {
+		return t.Punctuation
+	}
+	return color
+}
+
+// Besides the token types, text is highlighted as one of these roles
+const (
+	keyRole = -1 - iota
+	unparsedRole
+)
+
+// roleColor returns the colour of role, which is a token type, keyRole or
+// unparsedRole.
+func (t Theme) roleColor(role int) string {
+	switch role {
+	case keyRole:
+		return t.Key
+	case unparsedRole:
+		return t.Unparsed
+	}
+	return t.Color(role)
+}
+
+// The CSS classes that the printer marks text with, in the order Stylesheet
+// writes them in
+var classNames = []struct {
+	role int
+	name string
+}{
+	{keyRole, "j-key"},
+	{JSONString, "j-str"},
+	{JSONNumber, "j-num"},
+	{JSONTrue, "j-true"},
+	{JSONFalse, "j-false"},
+	{JSONNull, "j-null"},
+	{JSONOpenBrace, "j-brace"},
+	{JSONOpenSquareBracket, "j-bracket"},
+	{JSONColon, "j-colon"},
+	{JSONComma, "j-comma"},
+	{JSONInvalid, "j-err"},
+	{unparsedRole, "j-unparsed"},
+}
+
+// className returns the CSS class of role, which is a token type, keyRole or
+// unparsedRole.
+func className(role int) string {
+	switch role {
+	case JSONCloseBrace:
+		role = JSONOpenBrace
+	case JSONCloseSquareBracket:
+		role = JSONOpenSquareBracket
+	}
+	for _, class := range classNames {
+		if class.role == role {
+			return class.name
+		}
+	}
+	return "j-text"
+}
+
+func writeRules(buffer *bytes.Buffer, theme Theme, indent string) {
+	fmt.Fprintf(buffer, "%s.json { background-color: #%s; color: #%s; }\n", indent, theme.Background, theme.Foreground)
+	for _, class := range classNames {
+		fmt.Fprintf(buffer, "%s.%s { color: #%s; }\n", indent, class.name, theme.roleColor(class.role))
+	}
+}
+
+// Stylesheet returns the CSS that colours the output of a printer with
+// CSSClasses set. The element holding the output should have the class json,
+// which sets the background.
+func Stylesheet(theme Theme) string {
+	var buffer bytes.Buffer
+	writeRules(&buffer, theme, "")
+	buffer.WriteString(".j-err { text-decoration: underline wavy; }\n")
+	return buffer.String()
+}
+
+// ColorSchemeStylesheet is like Stylesheet, but uses dark instead of light when
+// the reader prefers a dark colour scheme.
+func ColorSchemeStylesheet(light, dark Theme) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(Stylesheet(light))
+	buffer.WriteString("@media (prefers-color-scheme: dark) {\n")
+	writeRules(&buffer, dark, "  ")
+	buffer.WriteString("}\n")
+	return buffer.String()
+}
+
+var (
+	// SolarizedDark is the theme that the printer has always used
+	SolarizedDark = Theme{
+		Name:        "solarized-dark",
+		Background:  "002b36",
+		Foreground:  "839496",
+		Key:         "2aa198",
+		String:      "2aa198",
+		Number:      "d33682",
+		True:        "b58900",
+		False:       "b58900",
+		Null:        "cb4b16",
+		Punctuation: "93a1a1",
+		Bracket:     "6c71c4",
+		Colon:       "268bd2",
+		Comma:       "859900",
+		Error:       "dc322f",
+		Unparsed:    "586e75",
+	}
+	// SolarizedLight is the light variant of SolarizedDark
+	SolarizedLight = Theme{
+		Name:        "solarized-light",
+		Background:  "fdf6e3",
+		Foreground:  "657b83",
+		Key:         "268bd2",
+		String:      "2aa198",
+		Number:      "d33682",
+		True:        "b58900",
+		False:       "b58900",
+		Null:        "cb4b16",
+		Punctuation: "586e75",
+		Bracket:     "6c71c4",
+		Colon:       "586e75",
+		Comma:       "859900",
+		Error:       "dc322f",
+		Unparsed:    "93a1a1",
+	}
+	// Monokai is modelled on the Monokai editor theme
+	Monokai = Theme{
+		Name:        "monokai",
+		Background:  "272822",
+		Foreground:  "f8f8f2",
+		Key:         "f92672",
+		String:      "e6db74",
+		Number:      "ae81ff",
+		True:        "66d9ef",
+		False:       "66d9ef",
+		Null:        "ae81ff",
+		Punctuation: "f8f8f2",
+		Error:       "ff5555",
+		Unparsed:    "75715e",
+	}
+	// GitHubLight is modelled on GitHub's light code highlighting
+	GitHubLight = Theme{
+		Name:        "github-light",
+		Background:  "ffffff",
+		Foreground:  "24292f",
+		Key:         "0550ae",
+		String:      "0a3069",
+		Number:      "0550ae",
+		True:        "cf222e",
+		False:       "cf222e",
+		Null:        "cf222e",
+		Punctuation: "24292f",
+		Error:       "82071e",
+		Unparsed:    "6e7781",
+	}
+	// GitHubDark is modelled on GitHub's dark code highlighting
+	GitHubDark = Theme{
+		Name:        "github-dark",
+		Background:  "0d1117",
+		Foreground:  "c9d1d9",
+		Key:         "79c0ff",
+		String:      "a5d6ff",
+		Number:      "79c0ff",
+		True:        "ff7b72",
+		False:       "ff7b72",
+		Null:        "ff7b72",
+		Punctuation: "c9d1d9",
+		Error:       "ffa198",
+		Unparsed:    "8b949e",
+	}
+	// HighContrast uses pure colours on black, for readability
+	HighContrast = Theme{
+		Name:        "high-contrast",
+		Background:  "000000",
+		Foreground:  "ffffff",
+		Key:         "ffff00",
+		String:      "00ff00",
+		Number:      "00ffff",
+		True:        "ff00ff",
+		False:       "ff00ff",
+		Null:        "ff00ff",
+		Punctuation: "ffffff",
+		Error:       "ff0000",
+		Unparsed:    "808080",
+	}
+)
+
+var themes = map[string]Theme{}
+
+func init() {
+	for _, theme := range []Theme{SolarizedDark, SolarizedLight, Monokai, GitHubLight, GitHubDark, HighContrast} {
+		themes[theme.Name] = theme
+	}
+}
+
+// LookupTheme returns the built-in theme with the given name
+func LookupTheme(name string) (Theme, bool) {
+	theme, ok := themes[name]
+	return theme, ok
+}
+
+// ThemeNames returns the names of the built-in themes, sorted
+func ThemeNames() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// colorFields lists the colours of a theme, for validating them
+func (t *Theme) colorFields() map[string]*string {
+	return map[string]*string{
+		"background": &t.Background, "foreground": &t.Foreground, "key": &t.Key,
+		"string": &t.String, "number": &t.Number, "true": &t.True, "false": &t.False,
+		"null": &t.Null, "punctuation": &t.Punctuation, "brace": &t.Brace,
+		"bracket": &t.Bracket, "colon": &t.Colon, "comma": &t.Comma,
+		"error": &t.Error, "unparsed": &t.Unparsed,
+	}
+}
+
+// LoadTheme reads a theme from a JSON object, such as
+//
+//	{"base": "monokai", "key": "#a6e22e", "comma": "f92672"}
+//
+// Colours that are left out are taken from the built-in theme named by base,
+// or from SolarizedDark. Colours may start with a '#'.
+func LoadTheme(r io.Reader) (Theme, error) {
+	var s scanner.Scanner
+	tokenizer := NewTokenizer(s.Init(r))
+	tree, err := Parse(&tokenizer)
+	if err != nil {
+		return Theme{}, err
+	}
+	object, ok := tree.(*ObjectNode)
+	if !ok {
+		return Theme{}, fmt.Errorf("a theme should be an object, not %s", tree.Kind())
+	}
+
+	theme := SolarizedDark
+	if base, ok := object.Get("base"); ok {
+		name, err := ToInterface(base)
+		if err != nil {
+			return Theme{}, err
+		}
+		if theme, ok = themes[fmt.Sprint(name)]; !ok {
+			return Theme{}, fmt.Errorf("unknown base theme %v", name)
+		}
+	}
+	theme.Name = ""
+	if err := Unmarshal(tree, &theme); err != nil {
+		return Theme{}, err
+	}
+
+	for field, color := range theme.colorFields() {
+		*color = strings.TrimPrefix(*color, "#")
+		if _, err := strconv.ParseUint(*color, 16, 32); *color != "" && (err != nil || len(*color) != 6) {
+			return Theme{}, fmt.Errorf("%s should be a hex colour such as 2aa198, not %q", field, *color)
+		}
+	}
+	return theme, nil
+}
@@ -0,0 +1,64 @@
+package json
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTheme(t *testing.T) {
+	for _, name := range ThemeNames() {
+		theme, ok := LookupTheme(name)
+		assert(ok && theme.Name == name, fmt.Sprintf("Should have found the theme %s", name))
+		for tokenType := range tokenNames {
+			assert(theme.Color(tokenType) != "", fmt.Sprintf("%s should have had a colour for %s", name, tokenName(tokenType)))
+		}
+	}
+	_, ok := LookupTheme("no such theme")
+	assert(!ok, "Should not have found a theme that doesn't exist")
+
+	assert(Monokai.Color(JSONComma) == Monokai.Punctuation, "Commas should have fallen back to the punctuation colour")
+	assert(SolarizedDark.Color(JSONComma) == "859900", "Commas should have kept their own colour")
+
+	theme, err := LoadTheme(strings.NewReader(`{"base": "monokai", "key": "#a6e22e", "comma": "F92672"}`))
+	assert(err == nil, fmt.Sprintf("Should have loaded the theme, but got %v", err))
+	assert(theme.Key == "a6e22e", "Should have dropped the # from the key colour")
+	assert(theme.Color(JSONComma) == "F92672", "Should have set the comma colour")
+	assert(theme.String == Monokai.String, "Should have taken the string colour from the base theme")
+
+	theme, err = LoadTheme(strings.NewReader(`{"string": "00ff00"}`))
+	assert(err == nil && theme.Number == SolarizedDark.Number, "Should have started from the default theme")
+
+	for _, input := range []string{`[]`, `{"base": "nope"}`, `{"key": "green"}`, `{"key": "#fff"}`, `{"key": 1}`, `{`} {
+		_, err := LoadTheme(strings.NewReader(input))
+		assert(err != nil, fmt.Sprintf("Should not have loaded %s", input))
+	}
+
+	options := DefaultPrinterOptions()
+	options.Theme = theme
+	output := printString(`{"a": "b"}`, options)
+	assert(strings.Count(output, "color:#00ff00") == 1, fmt.Sprintf("Should have coloured only the string with the theme, got %s", output))
+	assert(strings.Contains(output, "color:#"+theme.Key), "Should have coloured the key with the key colour")
+}
+
+func TestStylesheet(t *testing.T) {
+	options := DefaultPrinterOptions()
+	options.CSSClasses = true
+	output := printString(`{"a": [1, "b", true, null]}`, options)
+	assert(!strings.Contains(output, "style="), fmt.Sprintf("Should not have written any inline style, got %s", output))
+	for _, class := range []string{"j-brace", "j-key", "j-colon", "j-bracket", "j-num", "j-comma", "j-str", "j-true", "j-null"} {
+		assert(strings.Contains(output, "class='"+class+"'"), fmt.Sprintf("Should have marked a token with %s, got %s", class, output))
+	}
+
+	stylesheet := Stylesheet(Monokai)
+	assert(strings.Contains(stylesheet, ".json { background-color: #272822; color: #f8f8f2; }"), "Should have set the background")
+	assert(strings.Contains(stylesheet, ".j-key { color: #f92672; }"), "Should have coloured keys")
+	assert(strings.Contains(stylesheet, ".j-comma { color: #f8f8f2; }"), "Commas should have fallen back to the punctuation colour")
+	assert(!strings.Contains(stylesheet, "@media"), "Should not have had a dark colour scheme")
+
+	stylesheet = ColorSchemeStylesheet(GitHubLight, GitHubDark)
+	dark := strings.Index(stylesheet, "@media (prefers-color-scheme: dark) {")
+	assert(dark > 0, "Should have had a dark colour scheme")
+	assert(strings.Index(stylesheet, ".j-str { color: #"+GitHubLight.String) < dark, "Should have had the light colours first")
+	assert(strings.Index(stylesheet, ".j-str { color: #"+GitHubDark.String) > dark, "Should have had the dark colours in the media query")
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"text/scanner"
+	"unicode"
+	"unicode/utf16"
 	"unicode/utf8"
 )
 
@@ -22,6 +24,9 @@ const (
 	JSONComma
 	// JSONIdentifier represents an identifier (there are only 3 legal identifiers
 	// in go, techincally keywords)
+	//
+	// Deprecated: true, false and null are now tokenized as JSONTrue, JSONFalse
+	// and JSONNull, and nothing is tokenized as JSONIdentifier any more.
 	JSONIdentifier
 	// JSONString represents a string
 	JSONString
@@ -31,19 +36,65 @@ const (
 	JSONWhitespace
 	// JSONEnd represents the end of the JSON stream
 	JSONEnd
+	// JSONInvalid represents input that could not be tokenized; see Tokenizer.Err
+	JSONInvalid
+	// JSONTrue represents the literal true
+	JSONTrue
+	// JSONFalse represents the literal false
+	JSONFalse
+	// JSONNull represents the literal null
+	JSONNull
 )
 
+var tokenNames = map[int]string{
+	JSONOpenBrace:          "'{'",
+	JSONCloseBrace:         "'}'",
+	JSONOpenSquareBracket:  "'['",
+	JSONCloseSquareBracket: "']'",
+	JSONColon:              "':'",
+	JSONComma:              "','",
+	JSONIdentifier:         "identifier",
+	JSONTrue:               "true",
+	JSONFalse:              "false",
+	JSONNull:               "null",
+	JSONString:             "string",
+	JSONNumber:             "number",
+	JSONWhitespace:         "whitespace",
+	JSONEnd:                "end of input",
+	JSONInvalid:            "invalid token",
+}
+
+func tokenName(tokenType int) string {
+	if name, ok := tokenNames[tokenType]; ok {
+		return name
+	}
+	return fmt.Sprintf("token %d", tokenType)
+}
+
 // Tokenizer represents a tokenizer for a CharStrema
 type Tokenizer struct {
 	scanner      *scanner.Scanner
 	peekedTokens []Token
+	// line is the end of the line being read, which starts at lineColumn
+	line       []rune
+	lineColumn int
+	err        *SyntaxError
+	// lossless makes Scan return whitespace as JSONWhitespace tokens
+	lossless bool
+	// invalid is whether the last rune read was a byte of invalid UTF-8
+	invalid bool
 }
 
 // Token represents a token
 type Token struct {
+	// Content is the token as it appears in the input
 	Content   string
 	TokenType int
 	Position  scanner.Position
+	// End is the position immediately after the token
+	End scanner.Position
+	// Value is the decoded text of a string token
+	Value string
 }
 
 func (t Token) String() string {
@@ -52,43 +103,251 @@ func (t Token) String() string {
 
 // NewTokenizer initializes a new instance of a tokenizer.
 func NewTokenizer(reader *scanner.Scanner) Tokenizer {
-	return Tokenizer{reader, []Token{}}
+	return newTokenizer(reader, false)
 }
 
-func (t *Tokenizer) scanString() (string, error) {
-	hasBackslash := false
-	var buffer bytes.Buffer
-	buffer.WriteRune('"')
+// NewLosslessTokenizer returns a tokenizer that keeps the whitespace between
+// tokens as JSONWhitespace tokens, so that the contents of all the tokens add
+// up to the input. Parse expects a tokenizer from NewTokenizer instead.
+func NewLosslessTokenizer(reader *scanner.Scanner) Tokenizer {
+	return newTokenizer(reader, true)
+}
+
+func newTokenizer(reader *scanner.Scanner, lossless bool) Tokenizer {
+	// The tokenizer reports invalid input as syntax errors of its own, which the
+	// scanner would otherwise write to standard error as well
+	if reader.Error == nil {
+		reader.Error = func(*scanner.Scanner, string) {}
+	}
+	return Tokenizer{scanner: reader, peekedTokens: []Token{}, lineColumn: 1, lossless: lossless}
+}
+
+// Err returns the error behind the most recent JSONInvalid token, if any.
+func (t *Tokenizer) Err() error {
+	if t.err == nil {
+		return nil
+	}
+	return t.err
+}
+
+// next reads the next rune, keeping track of the line being read so that
+// errors can quote it.
+func (t *Tokenizer) next() rune {
+	offset := t.scanner.Pos().Offset
+	r := t.scanner.Next()
+	// The scanner reads each byte of invalid UTF-8 as utf8.RuneError, which
+	// takes up three bytes when it really is in the input
+	t.invalid = r == utf8.RuneError && t.scanner.Pos().Offset-offset == 1
+	if r == '\n' {
+		t.line = t.line[:0]
+		t.lineColumn = 1
+	} else if r != scanner.EOF {
+		t.line = append(t.line, r)
+		// Only the end of a long line is kept, such as that of minified JSON
+		if len(t.line) > 4*snippetWidth {
+			dropped := len(t.line) - 2*snippetWidth
+			t.line = t.line[:copy(t.line, t.line[dropped:])]
+			t.lineColumn += dropped
+		}
+	}
+	return r
+}
+
+// snippetWidth is how many runes of the line errors quote on either side of
+// where they are
+const snippetWidth = 40
+
+// lineAt returns the text read so far around the given position, with "..."
+// where it is cut short, and the column of the position within that text.
+func (t *Tokenizer) lineAt(position scanner.Position) (string, int) {
+	index := position.Column - t.lineColumn
+	if position.Line != t.scanner.Pos().Line || index < 0 || index > len(t.line) {
+		return "", 0
+	}
+	start, end := index-snippetWidth, index+snippetWidth
+	if start < 0 {
+		start = 0
+	}
+	if end > len(t.line) {
+		end = len(t.line)
+	}
+	text := string(t.line[start:end])
+	column := index - start + 1
+	if start > 0 || t.lineColumn > 1 {
+		text = "..." + text
+		column += 3
+	}
+	if end < len(t.line) {
+		text += "..."
+	}
+	return text, column
+}
+
+func (t *Tokenizer) errorf(position scanner.Position, format string, args ...interface{}) *SyntaxError {
+	line, column := t.lineAt(position)
+	t.err = &SyntaxError{
+		Msg:        fmt.Sprintf(format, args...),
+		Position:   position,
+		Line:       line,
+		lineColumn: column,
+	}
+	return t.err
+}
+
+// scanString reads the rest of a string, whose opening quote was at position.
+// It returns the string as it appears in the input, and its decoded value.
+func (t *Tokenizer) scanString(position scanner.Position) (string, string, error) {
+	var raw, value bytes.Buffer
+	var err error
+	raw.WriteRune('"')
 	for {
-		r := t.scanner.Next()
+		// Only the first error is reported, but the string is still read up to
+		// its closing quote so that scanning can carry on after it.
+		r := t.scanner.Peek()
+		switch {
+		case r == scanner.EOF:
+			if err == nil {
+				err = t.errorf(position, "unterminated string")
+			}
+			return raw.String(), value.String(), err
+		case r == '\n':
+			if err == nil {
+				err = t.errorf(t.scanner.Pos(), "newline in string")
+			}
+			return raw.String(), value.String(), err
+		}
+
+		charPosition := t.scanner.Pos()
+		t.next()
+		raw.WriteRune(r)
 		switch {
-		case r == '\\' && !hasBackslash:
-			hasBackslash = true
-		case r == '"' && !hasBackslash:
-			buffer.WriteRune(r)
-			return buffer.String(), nil // TODO: handle errors
+		case t.invalid:
+			if err == nil {
+				err = t.errorf(charPosition, "invalid UTF-8 encoding")
+			}
+		case r == '"':
+			return raw.String(), value.String(), err
+		case r == '\\':
+			decoded, e := t.scanEscape(&raw, charPosition)
+			if e != nil && err == nil {
+				err = e
+			}
+			value.WriteRune(decoded)
+		case r < 0x20:
+			if err == nil {
+				err = t.errorf(charPosition, "control character %U in string", r)
+			}
 		default:
-			hasBackslash = false
+			value.WriteRune(r)
 		}
-		buffer.WriteRune(r)
 	}
 }
 
+// scanEscape reads an escape sequence, whose backslash was at position, into
+// raw. It returns the rune that the sequence stands for.
+func (t *Tokenizer) scanEscape(raw *bytes.Buffer, position scanner.Position) (rune, error) {
+	r := t.scanner.Peek()
+	if r == scanner.EOF || r == '\n' {
+		return utf8.RuneError, t.errorf(position, "unterminated escape sequence")
+	}
+	t.next()
+	raw.WriteRune(r)
+	switch r {
+	case '"', '\\', '/':
+		return r, nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+		return t.scanUnicodeEscape(raw, position)
+	}
+	return utf8.RuneError, t.errorf(position, "invalid escape sequence \\%c", r)
+}
+
+// scanUnicodeEscape reads the hex digits of a \uXXXX escape sequence, and of
+// the low surrogate that must follow it if it is a high surrogate.
+func (t *Tokenizer) scanUnicodeEscape(raw *bytes.Buffer, position scanner.Position) (rune, error) {
+	r, ok := t.scanHex(raw)
+	if !ok {
+		return utf8.RuneError, t.errorf(position, "invalid \\u escape sequence")
+	}
+	if !utf16.IsSurrogate(r) {
+		return r, nil
+	}
+	if r >= 0xDC00 || t.scanner.Peek() != '\\' {
+		return utf8.RuneError, t.errorf(position, "unpaired surrogate \\u%04X", r)
+	}
+
+	t.next()
+	raw.WriteRune('\\')
+	if t.scanner.Peek() != 'u' {
+		return utf8.RuneError, t.errorf(position, "unpaired surrogate \\u%04X", r)
+	}
+	t.next()
+	raw.WriteRune('u')
+	low, ok := t.scanHex(raw)
+	if !ok {
+		return utf8.RuneError, t.errorf(position, "invalid \\u escape sequence")
+	}
+	if decoded := utf16.DecodeRune(r, low); decoded != unicode.ReplacementChar {
+		return decoded, nil
+	}
+	return utf8.RuneError, t.errorf(position, "unpaired surrogate \\u%04X", r)
+}
+
+// scanHex reads the four hex digits of a \uXXXX escape sequence into raw.
+func (t *Tokenizer) scanHex(raw *bytes.Buffer) (rune, bool) {
+	var value rune
+	for i := 0; i < 4; i++ {
+		r := t.scanner.Peek()
+		var digit rune
+		switch {
+		case r >= '0' && r <= '9':
+			digit = r - '0'
+		case r >= 'a' && r <= 'f':
+			digit = r - 'a' + 10
+		case r >= 'A' && r <= 'F':
+			digit = r - 'A' + 10
+		default:
+			return value, false
+		}
+		t.next()
+		raw.WriteRune(r)
+		value = value<<4 | digit
+	}
+	return value, true
+}
+
 // Finished represents whether or not the scanning is done.
 func (t *Tokenizer) Finished() bool {
 	return t.scanner.Peek() == scanner.EOF
 }
 
-func (t *Tokenizer) scanIdentifier() (string, error) {
+var literals = map[string]int{
+	"true":  JSONTrue,
+	"false": JSONFalse,
+	"null":  JSONNull,
+}
+
+// scanLiteral reads the rest of a run of letters, which must spell out one of
+// the three literals that JSON has.
+func (t *Tokenizer) scanLiteral(initial rune, position scanner.Position) (string, int, error) {
 	var buffer bytes.Buffer
-	for {
-		r := t.scanner.Peek()
-		if !isIdentifierRune(r) {
-			return buffer.String(), nil
-		}
-		buffer.WriteRune(r)
-		t.scanner.Next()
+	buffer.WriteRune(initial)
+	for isLiteralRune(t.scanner.Peek()) {
+		buffer.WriteRune(t.next())
+	}
+	if tokenType, ok := literals[buffer.String()]; ok {
+		return buffer.String(), tokenType, nil
 	}
+	return buffer.String(), JSONInvalid, t.errorf(position, "invalid literal %q, expected true, false or null", buffer.String())
 }
 
 // TODO: perhaps move all the digit parsing code to a seperate file for the sake
@@ -111,73 +370,79 @@ func (t *Tokenizer) scanDigits() (string, error) {
 			return buffer.String(), nil
 		}
 		buffer.WriteRune(r)
-		t.scanner.Next()
+		t.next()
 	}
 }
 
+// scanRequiredDigits reads one or more digits into buffer. Where describes
+// the part of the number that the digits belong to, for errors.
+func (t *Tokenizer) scanRequiredDigits(buffer *bytes.Buffer, where string) error {
+	position := t.scanner.Pos()
+	s, e := t.scanDigits()
+	if e != nil {
+		return e
+	}
+	if s == "" {
+		return t.errorf(position, "expected digit %s in number", where)
+	}
+	buffer.WriteString(s)
+	return nil
+}
+
+// scanNumber reads the rest of a number, following the grammar in RFC 8259:
+//
+//	number = [ minus ] int [ frac ] [ exp ]
 func (t *Tokenizer) scanNumber(initial rune) (string, error) {
 	var buffer bytes.Buffer
 	buffer.WriteRune(initial)
 
-	// Parse the second character (and onwards).
-	//
-	// Note: hopefully the next code seems like it may fail if the next character
-	//  seems to be an illegal character.
-	if initial != '0' && isRuneDigit(initial) {
+	first := initial
+	if initial == '-' {
+		first = t.scanner.Peek()
+		if !isRuneDigit(first) {
+			return buffer.String(), t.errorf(t.scanner.Pos(), "expected digit after '-' in number")
+		}
+		buffer.WriteRune(t.next())
+	}
+	if first != '0' {
 		s, e := t.scanDigits()
 		if e != nil {
-			return "", e
+			return buffer.String(), e
 		}
 		buffer.WriteString(s)
-	} else if initial == '-' {
-		peek := t.scanner.Peek()
-		if peek == '0' {
-			buffer.WriteRune(peek)
-			t.scanDigits()
-		} else {
-			s, e := t.scanDigits()
-			if e != nil {
-				return "", e
-			}
-			buffer.WriteString(s)
-		}
+	} else if isRuneDigit(t.scanner.Peek()) {
+		// Swallow the digits anyway, so that they are not read as another number
+		position := t.scanner.Pos()
+		s, _ := t.scanDigits()
+		buffer.WriteString(s)
+		return buffer.String(), t.errorf(position, "leading zero in number")
 	}
 
 	if t.scanner.Peek() == '.' {
-		buffer.WriteRune(t.scanner.Next())
-		s, e := t.scanDigits()
-		if e != nil {
-			return "", e
+		buffer.WriteRune(t.next())
+		if err := t.scanRequiredDigits(&buffer, "after decimal point"); err != nil {
+			return buffer.String(), err
 		}
-		buffer.WriteString(s)
 	}
 
 	if t.scanner.Peek() == 'e' || t.scanner.Peek() == 'E' {
-		buffer.WriteRune(t.scanner.Next())
+		buffer.WriteRune(t.next())
 		next := t.scanner.Peek()
 		if next == '-' || next == '+' {
-			buffer.WriteRune(next)
-			t.scanner.Next()
+			buffer.WriteRune(t.next())
 		}
-		s, e := t.scanDigits()
-		if e != nil {
-			return "", e
+		if err := t.scanRequiredDigits(&buffer, "in exponent"); err != nil {
+			return buffer.String(), err
 		}
-		buffer.WriteString(s)
 	}
 
 	return buffer.String(), nil
 }
 
-func isIdentifierRune(r rune) bool {
-	// TODO: not sure if this should be made a little bit more sophisticated.
-	s := string(r)
-	c := s[0]
-	if len(s) > 1 {
-		return false
-	}
-	// From the ascii table: http://www.asciitable.com/index/asciifull.gif
-	return utf8.RuneLen(r) == 1 && (c >= 65 && c <= 89 || c >= 97 && c <= 122)
+func isLiteralRune(r rune) bool {
+	// Anything that looks like a word is read in full, so that a bad literal is
+	// reported as a whole rather than a letter at a time.
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
 }
 
 func isNumberStart(r rune) bool {
@@ -202,7 +467,7 @@ func (t *Tokenizer) scanWhitespaces() (string, error) {
 			return buffer.String(), nil
 		}
 		buffer.WriteRune(r)
-		t.scanner.Next()
+		t.next()
 	}
 }
 
@@ -213,6 +478,11 @@ func (t *Tokenizer) Peek() Token {
 	return token
 }
 
+// unread puts back a token that was scanned, so that it is scanned again next.
+func (t *Tokenizer) unread(token Token) {
+	t.peekedTokens = append(t.peekedTokens, token)
+}
+
 // Scan scans the next token.
 func (t *Tokenizer) Scan() (Token, bool) {
 	// TODO: remove the boolean.
@@ -224,68 +494,62 @@ func (t *Tokenizer) Scan() (Token, bool) {
 		return token, false
 	}
 
-	position := t.scanner.Pos()
-	var r rune
-	for {
-		r = t.scanner.Next()
-		if !isInsignificantWhitespace(r) {
-			break
-		}
+	if t.lossless && isInsignificantWhitespace(t.scanner.Peek()) {
+		position := t.scanner.Pos()
+		s, _ := t.scanWhitespaces()
+		token := Token{Content: s, TokenType: JSONWhitespace, Position: position, End: t.scanner.Pos()}
+		return token, t.scanner.Peek() == scanner.EOF
 	}
+	for isInsignificantWhitespace(t.scanner.Peek()) {
+		t.next()
+	}
+	position := t.scanner.Pos()
+	r := t.next()
 	var token Token
 	switch {
 	case r == '"':
-		s, e := t.scanString()
+		s, value, e := t.scanString(position)
 		if e != nil {
-			panic("My scanner does not handle errors yet")
+			token = Token{Content: s, TokenType: JSONInvalid, Position: position}
+			break
 		}
-		token = Token{s, JSONString, position}
+		token = Token{Content: s, TokenType: JSONString, Position: position, Value: value}
 	case r == '{':
-		token = Token{string(r), JSONOpenBrace, position}
+		token = Token{Content: string(r), TokenType: JSONOpenBrace, Position: position}
 	case r == '}':
-		token = Token{string(r), JSONCloseBrace, position}
+		token = Token{Content: string(r), TokenType: JSONCloseBrace, Position: position}
 	case r == '[':
-		token = Token{string(r), JSONOpenSquareBracket, position}
+		token = Token{Content: string(r), TokenType: JSONOpenSquareBracket, Position: position}
 	case r == ']':
-		token = Token{string(r), JSONCloseSquareBracket, position}
+		token = Token{Content: string(r), TokenType: JSONCloseSquareBracket, Position: position}
 	case r == ':':
-		token = Token{string(r), JSONColon, position}
+		token = Token{Content: string(r), TokenType: JSONColon, Position: position}
 	case r == ',':
-		token = Token{string(r), JSONComma, position}
-	case isIdentifierRune(r):
-		var toCat bytes.Buffer
-		toCat.WriteRune(r)
-		s, e := t.scanIdentifier()
-		if e != nil {
-			panic("My scanner does not handle errors yet")
-		}
-		toCat.WriteString(s)
-		token = Token{toCat.String(), JSONIdentifier, position}
+		token = Token{Content: string(r), TokenType: JSONComma, Position: position}
+	case isLiteralRune(r):
+		s, tokenType, _ := t.scanLiteral(r, position)
+		token = Token{Content: s, TokenType: tokenType, Position: position}
 	case isNumberStart(r):
 		var toCat bytes.Buffer
 		s, e := t.scanNumber(r)
+		toCat.WriteString(s)
 		if e != nil {
-			panic("My scanner does not handle errors yet")
+			token = Token{Content: toCat.String(), TokenType: JSONInvalid, Position: position}
+			break
 		}
-		toCat.WriteString(s)
-		token = Token{toCat.String(), JSONNumber, position}
-	case isInsignificantWhitespace(r):
-		// The assignment does not require that we reconstruct the white spaces
-		// and therefore, we will just ignore them. So fuck it.
-		// var toCat bytes.Buffer
-		// toCat.WriteRune(r)
-		// s, e := t.scanWhitespaces()
-		// if e != nil {
-		// 	panic("My scanner does not handle errors yet")
-		// }
-		// toCat.WriteString(s)
-		// token = Token{toCat.String(), JSONWhitespace, position}
-		panic("We should not be here.")
-		// Maybe in the future we will consider
+		token = Token{Content: toCat.String(), TokenType: JSONNumber, Position: position}
 	case r == scanner.EOF:
-		return Token{string(r), JSONEnd, position}, true
+		return Token{Content: "", TokenType: JSONEnd, Position: position, End: position}, true
+	case t.invalid:
+		t.errorf(position, "invalid UTF-8 encoding")
+		token = Token{Content: string(r), TokenType: JSONInvalid, Position: position}
 	default:
-		panic("I wrote a bad scanner")
+		t.errorf(position, "unexpected character %q", r)
+		token = Token{Content: string(r), TokenType: JSONInvalid, Position: position}
+	}
+	token.End = t.scanner.Pos()
+	if token.TokenType == JSONInvalid {
+		t.err.Found = token
 	}
 	return token, t.scanner.Peek() == scanner.EOF
 }
@@ -39,7 +39,7 @@ func TestScan(t *testing.T) {
 	tokenizer := NewTokenizer(reader)
 	token, done := tokenizer.Scan()
 	assert(done, "Should have reached the last token")
-	assert(token.Content == "{", fmt.Sprintf("%c should be {", token.Content))
+	assert(token.Content == "{", fmt.Sprintf("%s should be {", token.Content))
 	assert(token.TokenType == JSONOpenBrace, fmt.Sprintf("Token type should have been labeled as open brace"))
 
 	reader = s.Init(strings.NewReader("{}"))
@@ -47,7 +47,7 @@ func TestScan(t *testing.T) {
 	tokenizer.Scan()
 	token, done = tokenizer.Scan()
 	assert(done, "Should have reaced the last token")
-	assert(token.Content == "}", fmt.Sprintf("%c should be }", token.Content))
+	assert(token.Content == "}", fmt.Sprintf("%s should be }", token.Content))
 	assert(token.TokenType == JSONCloseBrace, fmt.Sprintf("Token type should have been labeled as a close brace"))
 
 	reader = s.Init(strings.NewReader(stringLitTestString))
@@ -96,3 +96,122 @@ func TestScan(t *testing.T) {
 	str = getAllTokens(tokenizer)
 	assert(str == "[{},2]", "Should be able to reconstruct")
 }
+
+func scanOne(input string) Token {
+	var s scanner.Scanner
+	tokenizer := NewTokenizer(s.Init(strings.NewReader(input)))
+	token, _ := tokenizer.Scan()
+	return token
+}
+
+func testValidString(lit, value string) {
+	token := scanOne(lit)
+	assert(token.TokenType == JSONString, fmt.Sprintf("Expected %s to be a string", lit))
+	assert(token.Content == lit, fmt.Sprintf("Expected content to be '%s', but instead got '%s'", lit, token.Content))
+	assert(token.Value == value, fmt.Sprintf("Expected %s to decode to %q, but instead got %q", lit, value, token.Value))
+}
+
+func testInvalidString(lit, message string) {
+	var s scanner.Scanner
+	tokenizer := NewTokenizer(s.Init(strings.NewReader(lit)))
+	token, _ := tokenizer.Scan()
+	assert(token.TokenType == JSONInvalid, fmt.Sprintf("Expected %q to be rejected", lit))
+	err, ok := tokenizer.Err().(*SyntaxError)
+	assert(ok, fmt.Sprintf("Expected a syntax error for %q", lit))
+	assert(err.Msg == message, fmt.Sprintf("Expected %q for %q, but instead got %q", message, lit, err.Msg))
+}
+
+func TestScanString(t *testing.T) {
+	testValidString(`""`, "")
+	testValidString(`"abc"`, "abc")
+	testValidString(`"\"\\\/\b\f\n\r\t"`, "\"\\/\b\f\n\r\t")
+	testValidString(`"\u00e9\u00E9"`, "éé")
+	testValidString(`"\ud83d\ude00"`, "😀")
+	testValidString(`"日本"`, "日本")
+	testValidString("\"\uFFFD\"", "\uFFFD")
+
+	testInvalidString(`"abc`, "unterminated string")
+	testInvalidString("\"ab\ncd\"", "newline in string")
+	testInvalidString("\"a\tb\"", "control character U+0009 in string")
+	testInvalidString(`"\x"`, `invalid escape sequence \x`)
+	testInvalidString(`"\u12"`, `invalid \u escape sequence`)
+	testInvalidString(`"\ud83d"`, `unpaired surrogate \uD83D`)
+	testInvalidString(`"\ud83dA"`, `unpaired surrogate \uD83D`)
+	testInvalidString(`"\ude00"`, `unpaired surrogate \uDE00`)
+	testInvalidString("\"a\xffb\"", "invalid UTF-8 encoding")
+
+	// Scanning carries on after the end of a bad string
+	var s scanner.Scanner
+	tokenizer := NewTokenizer(s.Init(strings.NewReader(`["\x", 1]`)))
+	str := getAllTokens(tokenizer)
+	assert(str == `["\x",1]`, fmt.Sprintf("Should have carried on after the bad string, but got %s", str))
+
+	token := scanOne("\xff")
+	assert(token.TokenType == JSONInvalid, "Invalid UTF-8 outside a string should have been rejected")
+}
+
+func TestScanInvalidNumber(t *testing.T) {
+	invalid := map[string]string{
+		"-":   "expected digit after '-' in number",
+		"-a":  "expected digit after '-' in number",
+		"1.":  "expected digit after decimal point in number",
+		"1.e": "expected digit after decimal point in number",
+		"1e":  "expected digit in exponent in number",
+		"1e+": "expected digit in exponent in number",
+		"01":  "leading zero in number",
+		"-01": "leading zero in number",
+	}
+	for lit, message := range invalid {
+		var s scanner.Scanner
+		tokenizer := NewTokenizer(s.Init(strings.NewReader(lit)))
+		token, _ := tokenizer.Scan()
+		assert(token.TokenType == JSONInvalid, fmt.Sprintf("Expected %q to be rejected", lit))
+		err := tokenizer.Err().(*SyntaxError)
+		assert(err.Msg == message, fmt.Sprintf("Expected %q for %q, but instead got %q", message, lit, err.Msg))
+	}
+
+	var s scanner.Scanner
+	tokenizer := NewTokenizer(s.Init(strings.NewReader("1.x")))
+	tokenizer.Scan()
+	err := tokenizer.Err().(*SyntaxError)
+	assert(err.Position.Column == 3, fmt.Sprintf("Should have pointed after the decimal point, but pointed at %s", err.Position))
+}
+
+func TestScanLiteral(t *testing.T) {
+	assert(scanOne("true").TokenType == JSONTrue, "Should have scanned true")
+	assert(scanOne("false").TokenType == JSONFalse, "Should have scanned false")
+	assert(scanOne("null").TokenType == JSONNull, "Should have scanned null")
+
+	for _, lit := range []string{"undefined", "nul", "True", "NULL", "Zebra", "truex"} {
+		var s scanner.Scanner
+		tokenizer := NewTokenizer(s.Init(strings.NewReader(lit)))
+		token, _ := tokenizer.Scan()
+		assert(token.TokenType == JSONInvalid, fmt.Sprintf("Expected %q to be rejected", lit))
+		assert(token.Content == lit, fmt.Sprintf("Expected the whole of %q to be read, but got %q", lit, token.Content))
+		err := tokenizer.Err().(*SyntaxError)
+		assert(err.Position.Column == 1, fmt.Sprintf("Should have pointed at the start of %q", lit))
+	}
+}
+
+func TestScanLossless(t *testing.T) {
+	input := "{ \"a\" :\r\n\t[1,tru ]}\n"
+	var s scanner.Scanner
+	tokenizer := NewLosslessTokenizer(s.Init(strings.NewReader(input)))
+	var content bytes.Buffer
+	var types []int
+	for {
+		token, _ := tokenizer.Scan()
+		if token.TokenType == JSONEnd {
+			break
+		}
+		assert(input[token.Position.Offset:token.End.Offset] == token.Content,
+			fmt.Sprintf("%s should have spanned its content", token))
+		content.WriteString(token.Content)
+		types = append(types, token.TokenType)
+	}
+	assert(content.String() == input, fmt.Sprintf("Should have kept everything, but got %q", content.String()))
+	expected := []int{JSONOpenBrace, JSONWhitespace, JSONString, JSONWhitespace, JSONColon, JSONWhitespace,
+		JSONOpenSquareBracket, JSONNumber, JSONComma, JSONInvalid, JSONWhitespace, JSONCloseSquareBracket,
+		JSONCloseBrace, JSONWhitespace}
+	assert(fmt.Sprint(types) == fmt.Sprint(expected), fmt.Sprintf("Expected %v, but got %v", expected, types))
+}
@@ -0,0 +1,44 @@
+package json
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/scanner"
+)
+
+// Diagnostic is a problem with a document, as found by Validate
+type Diagnostic struct {
+	// Position is where the problem starts, and End is just after the token
+	// that it was found at
+	Position, End scanner.Position
+	Message       string
+}
+
+// String returns the diagnostic as file:line:column: message, the file being
+// left out if the positions have no Filename.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Position, d.Message)
+}
+
+// Validate reads a document and returns every problem with it, in the order
+// they appear in, without building anything to print. A valid document has no
+// diagnostics. The positions have no Filename, which callers can fill in.
+func Validate(r io.Reader) []Diagnostic {
+	var diagnostics []Diagnostic
+	var s scanner.Scanner
+	s.Init(r)
+	tokenizer := NewTokenizer(&s)
+	_, errs := ParseTolerant(&tokenizer)
+	for _, err := range errs {
+		end := err.Found.End
+		if end.Line == 0 {
+			end = err.Position
+		}
+		diagnostics = append(diagnostics, Diagnostic{err.Position, end, err.Msg})
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Position.Offset < diagnostics[j].Position.Offset
+	})
+	return diagnostics
+}
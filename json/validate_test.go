@@ -0,0 +1,24 @@
+package json
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	assert(len(Validate(strings.NewReader(`{"a": [1, 2.5e3, "x"]}`))) == 0, "A valid document should not have had diagnostics")
+
+	diagnostics := Validate(strings.NewReader("{\"a\": 1\n  \"b\": 2, \"c\": tru}"))
+	assert(len(diagnostics) == 2, fmt.Sprintf("Expected 2 diagnostics, but got %v", diagnostics))
+	first, second := diagnostics[0], diagnostics[1]
+	assert(first.Position.Line == 2 && first.Position.Column == 3, fmt.Sprintf("Wrong position %s", first.Position))
+	assert(first.End.Line == 2 && first.End.Column == 6, fmt.Sprintf("Should have ended after \"b\", not at %s", first.End))
+	assert(first.Message == `expected ',' or '}', found string "b"`, fmt.Sprintf("Wrong message %q", first.Message))
+	assert(second.Position.Column == 16 && second.End.Column == 19, fmt.Sprintf("Should have spanned tru, not %s to %s", second.Position, second.End))
+	assert(strings.HasSuffix(second.String(), `2:16: invalid literal "tru", expected true, false or null`), second.String())
+
+	diagnostics = Validate(strings.NewReader("\"\xff\""))
+	assert(len(diagnostics) == 1 && diagnostics[0].Message == "invalid UTF-8 encoding",
+		fmt.Sprintf("Should have reported the invalid UTF-8, but got %v", diagnostics))
+}
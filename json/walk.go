@@ -0,0 +1,134 @@
+package json
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is the location of a node in a tree, as the property names and array
+// indices that lead to it from the root
+type Path []string
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// String returns the path as a JSON Pointer (RFC 6901), such as /widgets/2.
+// The root is the empty string.
+func (p Path) String() string {
+	var buffer bytes.Buffer
+	for _, token := range p {
+		buffer.WriteRune('/')
+		buffer.WriteString(pointerEscaper.Replace(token))
+	}
+	return buffer.String()
+}
+
+// ParsePointer parses a JSON Pointer (RFC 6901), such as /widgets/2, into a
+// path. The empty string is the root.
+func ParsePointer(pointer string) (Path, error) {
+	if pointer == "" {
+		return Path{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("JSON Pointer %q should start with '/'", pointer)
+	}
+	path := Path(strings.Split(pointer[1:], "/"))
+	for i, token := range path {
+		// A ~ has to be followed by 0 or 1
+		if strings.Count(token, "~") != strings.Count(token, "~0")+strings.Count(token, "~1") {
+			return nil, fmt.Errorf("JSON Pointer %q has a '~' that is not followed by 0 or 1", pointer)
+		}
+		path[i] = pointerUnescaper.Replace(token)
+	}
+	return path, nil
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// Find returns the node at path under node. An object is searched for the
+// first property with the key, and an array for the element at the index,
+// which has to be written without leading zeros.
+func Find(node Node, path Path) (Node, bool) {
+	for _, token := range path {
+		if property, ok := node.(*PropertyNode); ok {
+			node = property.value
+		}
+		switch container := node.(type) {
+		case *ObjectNode:
+			var ok bool
+			if node, ok = container.Get(token); !ok {
+				return nil, false
+			}
+		case *ArrayNode:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(container.elements) || strconv.Itoa(i) != token {
+				return nil, false
+			}
+			node = container.elements[i]
+		default:
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// child returns a new path, so that the paths handed out by Walk can be kept
+// without being overwritten by the paths of later nodes.
+func (p Path) child(token string) Path {
+	path := make(Path, len(p), len(p)+1)
+	copy(path, p)
+	return append(path, token)
+}
+
+// Visitor is called for every node by Walk
+type Visitor interface {
+	// Enter is called before the children of node are walked. The children are
+	// skipped if it returns false.
+	Enter(node Node, path Path) bool
+	// Leave is called after the children of node were walked. It is only called
+	// if Enter returned true.
+	Leave(node Node, path Path)
+}
+
+// Walk traverses the tree in depth-first order, starting with node. A property
+// and its value share the same path, the property being entered first.
+func Walk(node Node, v Visitor) {
+	walk(node, Path{}, v)
+}
+
+func walk(node Node, path Path, v Visitor) {
+	if !v.Enter(node, path) {
+		return
+	}
+	switch node := node.(type) {
+	case *ObjectNode:
+		for _, property := range node.properties {
+			walk(property, path.child(property.Key()), v)
+		}
+	case *ArrayNode:
+		for i, element := range node.elements {
+			walk(element, path.child(strconv.Itoa(i)), v)
+		}
+	case *PropertyNode:
+		walk(node.value, path, v)
+	}
+	v.Leave(node, path)
+}
+
+type inspector func(Node, Path) bool
+
+func (f inspector) Enter(node Node, path Path) bool {
+	return f(node, path)
+}
+
+func (f inspector) Leave(node Node, path Path) {
+	f(nil, path)
+}
+
+// Inspect traverses the tree in depth-first order, like Walk. It calls f for
+// every node, and skips the children of a node if f returns false. Once the
+// children of a node are done, f is called with a nil node.
+func Inspect(node Node, f func(Node, Path) bool) {
+	Walk(node, inspector(f))
+}
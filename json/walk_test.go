@@ -0,0 +1,76 @@
+package json
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type countingVisitor struct {
+	entered, left int
+}
+
+func (v *countingVisitor) Enter(node Node, path Path) bool {
+	v.entered++
+	// Don't bother with the inside of arrays
+	return node.Kind() != ArrayKind
+}
+
+func (v *countingVisitor) Leave(node Node, path Path) {
+	v.left++
+}
+
+func TestWalk(t *testing.T) {
+	tree := parseString("{\"a/b\": [1, {\"c~\": true}], \"d\": null}")
+
+	var paths []string
+	var kinds []string
+	Inspect(tree, func(node Node, path Path) bool {
+		if node != nil {
+			paths = append(paths, path.String())
+			kinds = append(kinds, node.Kind().String())
+		}
+		return true
+	})
+	expected := []string{"", "/a~1b", "/a~1b", "/a~1b/0", "/a~1b/1", "/a~1b/1/c~0", "/a~1b/1/c~0", "/d", "/d"}
+	assert(strings.Join(paths, " ") == strings.Join(expected, " "), fmt.Sprintf("Wrong paths %v", paths))
+	assert(kinds[1] == "property" && kinds[2] == "array", fmt.Sprintf("Properties should come before their values, but got %v", kinds))
+
+	visitor := &countingVisitor{}
+	Walk(tree, visitor)
+	assert(visitor.entered == 5, fmt.Sprintf("Should have entered 5 nodes, but entered %d", visitor.entered))
+	assert(visitor.left == 4, fmt.Sprintf("Should have left 4 nodes, but left %d", visitor.left))
+
+	assert(Path{}.String() == "", "The root should be the empty pointer")
+}
+
+func TestPointer(t *testing.T) {
+	tree := parseString("{\"a/b\": [1, {\"c~\": true}], \"\": 2}")
+
+	for pointer, expected := range map[string]string{
+		"":            "object",
+		"/a~1b":       "array",
+		"/a~1b/0":     "number",
+		"/a~1b/1/c~0": "bool",
+		"/":           "number",
+	} {
+		path, err := ParsePointer(pointer)
+		assert(err == nil, fmt.Sprintf("Should have parsed %q, but got %v", pointer, err))
+		assert(path.String() == pointer, fmt.Sprintf("%q should have round tripped, but got %q", pointer, path))
+		node, ok := Find(tree, path)
+		assert(ok && node.Kind().String() == expected, fmt.Sprintf("%q should have found a %s", pointer, expected))
+	}
+	for _, pointer := range []string{"/a~1b/01", "/a~1b/2", "/a~1b/-1", "/a~1b/0/x", "/nope", "/a~1b/1/c~"} {
+		path, err := ParsePointer(pointer)
+		if err == nil {
+			_, ok := Find(tree, path)
+			assert(!ok, fmt.Sprintf("%q should not have found anything", pointer))
+		}
+	}
+	for _, pointer := range []string{"a", "/a~2", "/~"} {
+		_, err := ParsePointer(pointer)
+		assert(err != nil, fmt.Sprintf("%q should not have parsed", pointer))
+	}
+	path, _ := ParsePointer("/~01")
+	assert(path[0] == "~1", fmt.Sprintf("~01 should have been ~1, not %q", path[0]))
+}
@@ -1,43 +1,215 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"text/scanner"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"./json"
 )
 
-func main() {
-	args := os.Args[1:]
-	if len(args) < 1 {
-		fmt.Printf("Please provide a filename\n")
-		os.Exit(1)
+// version is the version of the program, which can be set when building with
+// -ldflags "-X main.version=..."
+var version = "0.2.0"
+
+// parseIndent turns the -indent flag into the string to indent with: either a
+// number of spaces, "tab", or the indentation itself, made of spaces and tabs.
+func parseIndent(indent string) (string, error) {
+	if n, err := strconv.Atoi(indent); err == nil && n >= 0 {
+		return strings.Repeat(" ", n), nil
+	} else if indent == "tab" {
+		return "\t", nil
+	} else if strings.Trim(indent, " \t") == "" {
+		return indent, nil
+	}
+	return "", fmt.Errorf("invalid indentation %q, expected a number of spaces, \"tab\", or spaces and tabs", indent)
+}
+
+// isTerminal reports whether f is a terminal, rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// detectColorMode works out how many colours out can show, from the NO_COLOR,
+// COLORTERM and TERM environment variables. Only terminals get colours, and
+// out is nil for files named by -o.
+func detectColorMode(out *os.File) json.ColorMode {
+	term := os.Getenv("TERM")
+	switch {
+	case os.Getenv("NO_COLOR") != "" || out == nil || !isTerminal(out) || term == "dumb":
+		return json.ColorNone
+	case os.Getenv("COLORTERM") == "truecolor" || os.Getenv("COLORTERM") == "24bit":
+		return json.ColorTrueColor
+	case strings.Contains(term, "256color"):
+		return json.Color256
+	}
+	return json.Color16
+}
+
+// parseColorMode turns the -color flag into a colour mode, for writing to out
+func parseColorMode(color string, out *os.File) (json.ColorMode, error) {
+	switch color {
+	case "auto":
+		return detectColorMode(out), nil
+	case "never":
+		return json.ColorNone, nil
+	case "16":
+		return json.Color16, nil
+	case "256":
+		return json.Color256, nil
+	case "truecolor", "24bit":
+		return json.ColorTrueColor, nil
 	}
-	var s scanner.Scanner
-	f, err := os.Open(args[0])
+	return json.ColorNone, fmt.Errorf("unknown color mode %q", color)
+}
+
+// loadTheme turns the -theme flag into a theme: either the name of a built-in
+// theme, or the path to a JSON file describing one.
+func loadTheme(name string) (json.Theme, error) {
+	if theme, ok := json.LookupTheme(name); ok {
+		return theme, nil
+	}
+	if !strings.HasSuffix(name, ".json") {
+		return json.Theme{}, fmt.Errorf("unknown theme %q, expected one of %s or a .json file",
+			name, strings.Join(json.ThemeNames(), ", "))
+	}
+	file, err := os.Open(name)
 	if err != nil {
-		panic(err)
+		return json.Theme{}, err
+	}
+	defer file.Close()
+	theme, err := json.LoadTheme(file)
+	if err != nil {
+		return json.Theme{}, fmt.Errorf("%s: %v", name, err)
+	}
+	return theme, nil
+}
+
+// The statuses that the program exits with, besides 0 when all went well
+const (
+	// exitInvalid means that a document is not valid JSON, or that a command
+	// found what it was checking for, such as differences
+	exitInvalid = 1
+	// exitTrouble means that a file could not be read or written, or that the
+	// flags were wrong
+	exitTrouble = 2
+)
+
+// command is a subcommand of the program
+type command struct {
+	name string
+	// arguments describes the arguments that follow the flags
+	arguments string
+	summary   string
+	run       func(flags *flag.FlagSet, args []string) int
+}
+
+var commands = []command{
+	{"format", "[file | pattern | -]...", "Lay documents out again, and highlight them. This is the default command.", runFormat},
+	{"highlight", "[file | pattern | -]...", "Highlight documents exactly as they are, without laying them out again.", runHighlight},
+	{"validate", "[file | pattern | -]...", "Check that documents are valid JSON, without printing them.", runValidate},
+	{"query", "<pointer> [file | pattern | -]...", "Print the value at a JSON Pointer, such as /widgets/2, of every document.", runQuery},
+	{"diff", "<old> <new>", "List the values that were added, removed or changed from one document to another.", runDiff},
+}
+
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// usage describes the program and its commands
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [flags] [arguments]\n\n", programName())
+	fmt.Fprintln(w, "Files are read in order, standard input if there are none or for \"-\".")
+	fmt.Fprintln(w, "Exits with 1 if a document is not valid JSON, and 2 if a file can not be read.")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, command := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", command.name, command.summary)
 	}
-	scanner := s.Init(f)
-	tokenizer := json.NewTokenizer(scanner)
+	fmt.Fprintf(w, "\nRun \"%s help <command>\" for the flags of a command, or \"%s -version\" for the version.\n",
+		programName(), programName())
+}
 
-	tree := json.Parse(&tokenizer)
+func findCommand(name string) (command, bool) {
+	for _, command := range commands {
+		if command.name == name {
+			return command, true
+		}
+	}
+	return command{}, false
+}
 
-	fmt.Printf("%s", `<!doctype html>
-	<html lang='en'>
-		<head>
-			<meta charset='utf-8'>
-			<title>Here ye some JSON!</title>
-		</head>
-		<body style="padding 0; margin: 0; background-color: #002b36">
-			<div style="padding: 5px">
-	<span style="font-family:monospace; white-space:pre">`)
+// newFlagSet returns the flags of command, to which it adds its own
+func newFlagSet(command command) *flag.FlagSet {
+	flags := flag.NewFlagSet(command.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n",
+			programName(), command.name, command.arguments, command.summary)
+		flags.PrintDefaults()
+	}
+	return flags
+}
 
-	json.PrintTree(tree, 0)
+// parseFlags parses the flags of a command, which may come before, after or in
+// between its arguments, up to a "--". It returns false, along with the status
+// to exit with, if the command should stop there, such as after -help.
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	var arguments []string
+	for {
+		err := flags.Parse(args)
+		if err == flag.ErrHelp {
+			return 0, false
+		} else if err != nil {
+			return exitTrouble, false
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			break
+		}
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			arguments = append(arguments, rest...)
+			break
+		}
+		arguments = append(arguments, rest[0])
+		args = rest[1:]
+	}
+	// Leave the arguments for flags.Args
+	flags.Parse(append([]string{"--"}, arguments...))
+	return 0, true
+}
 
-	fmt.Printf("%s", `</span>
-			</div>
-		</body>
-	</html>`)
+// run runs the command that args start with, or format if they don't start
+// with one, and returns the status to exit with.
+func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "-version", "--version", "version":
+			fmt.Printf("%s %s\n", programName(), version)
+			return 0
+		case "-h", "-help", "--help", "help":
+			if len(args) > 1 {
+				if command, ok := findCommand(args[1]); ok {
+					return command.run(newFlagSet(command), []string{"-help"})
+				}
+				fmt.Fprintf(os.Stderr, "unknown command %q\n", args[1])
+				usage(os.Stderr)
+				return exitTrouble
+			}
+			usage(os.Stdout)
+			return 0
+		}
+		if command, ok := findCommand(args[0]); ok {
+			return command.run(newFlagSet(command), args[1:])
+		}
+	}
+	format, _ := findCommand("format")
+	return format.run(newFlagSet(format), args)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
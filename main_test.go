@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// capture calls f with standard output and standard error going to files, and
+// returns what was written to them
+func capture(t *testing.T, f func()) (string, string) {
+	dir := t.TempDir()
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	assert(err == nil, fmt.Sprintf("Should have created the standard output, but got %v", err))
+	stderr, err := os.Create(filepath.Join(dir, "stderr"))
+	assert(err == nil, fmt.Sprintf("Should have created the standard error, but got %v", err))
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdout, stderr
+	f()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	stdout.Close()
+	stderr.Close()
+
+	written, _ := os.ReadFile(stdout.Name())
+	problems, _ := os.ReadFile(stderr.Name())
+	return string(written), string(problems)
+}
+
+func TestParseFlags(t *testing.T) {
+	newFlags := func() (*flag.FlagSet, *bool, *string) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		return flags, flags.Bool("b", false, ""), flags.String("s", "", "")
+	}
+
+	flags, b, s := newFlags()
+	status, ok := parseFlags(flags, []string{"a", "-b", "c", "-s", "x", "d"})
+	assert(ok && status == 0, "Should have parsed the flags")
+	assert(*b && *s == "x", fmt.Sprintf("Should have set the flags between the arguments, not %v and %q", *b, *s))
+	assert(strings.Join(flags.Args(), " ") == "a c d", fmt.Sprintf("Wrong arguments %q", flags.Args()))
+
+	// Whatever follows "--" is an argument, even if it looks like a flag
+	flags, b, s = newFlags()
+	_, ok = parseFlags(flags, []string{"-s", "x", "a", "--", "-b", "--"})
+	assert(ok && !*b && *s == "x", fmt.Sprintf("Should only have set -s, not %v and %q", *b, *s))
+	assert(strings.Join(flags.Args(), " ") == "a -b --", fmt.Sprintf("Wrong arguments %q", flags.Args()))
+
+	flags, _, _ = newFlags()
+	_, ok = parseFlags(flags, []string{"-", "-b"})
+	assert(ok && strings.Join(flags.Args(), " ") == "-", fmt.Sprintf("Should have kept standard input as an argument, not %q", flags.Args()))
+
+	flags, _, _ = newFlags()
+	status, ok = parseFlags(flags, []string{"a", "-help"})
+	assert(!ok && status == 0, "Should have stopped after -help")
+
+	flags, _, _ = newFlags()
+	status, ok = parseFlags(flags, []string{"a", "-unknown"})
+	assert(!ok && status == exitTrouble, "Should have rejected an unknown flag")
+}
+
+func TestRun(t *testing.T) {
+	var status int
+	stdout, _ := capture(t, func() { status = run([]string{"-version"}) })
+	assert(status == 0 && stdout == programName()+" "+version+"\n", fmt.Sprintf("Wrong version %q", stdout))
+
+	stdout, _ = capture(t, func() { status = run([]string{"help"}) })
+	assert(status == 0 && strings.Contains(stdout, "Commands:"), fmt.Sprintf("Should have listed the commands, not %q", stdout))
+
+	_, stderr := capture(t, func() { status = run([]string{"help", "query"}) })
+	assert(status == 0, fmt.Sprintf("Should have described query, but exited with %d", status))
+	assert(strings.HasPrefix(stderr, "Usage: "+programName()+" query [flags] <pointer>"), fmt.Sprintf("Should have described query, not %q", stderr))
+	assert(strings.Contains(stderr, "-format"), "Should have listed the flags of query")
+
+	_, stderr = capture(t, func() { status = run([]string{"help", "unknown"}) })
+	assert(status == exitTrouble && strings.HasPrefix(stderr, "unknown command \"unknown\"\n"), fmt.Sprintf("Should have rejected the command, not %q", stderr))
+
+	// Without a command, documents are formatted
+	root := makeTree(t, map[string]string{"a.json": `{"a":[1,2],"b":"x"}`})
+	expected := "{\n    \"a\": [ 1, 2 ]\n  , \"b\": \"x\"\n}\n"
+	stdout, _ = capture(t, func() { status = run([]string{filepath.Join(root, "a.json"), "-format", "text"}) })
+	assert(status == 0 && stdout == expected, fmt.Sprintf("Should have formatted the document, not %q", stdout))
+	stdout, _ = capture(t, func() { status = run([]string{"format", "-format", "text", filepath.Join(root, "a.json")}) })
+	assert(status == 0 && stdout == expected, fmt.Sprintf("Should have formatted the document with the command, not %q", stdout))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"time"
+
+	"./json"
+)
+
+// pageData is what a -template is executed with
+type pageData struct {
+	Title string
+	// Stylesheet is the CSS for the page, empty if StylesheetURL is set
+	Stylesheet template.CSS
+	// StylesheetURL links to the file the stylesheet was written to with -css-out,
+	// relative to the page
+	StylesheetURL string
+	// Body is the highlighted document, as a <pre> block
+	Body template.HTML
+	// Script and Controls are what -collapsible needs to fold the document,
+	// both empty without it
+	Script   template.JS
+	Controls template.HTML
+	// FileName and Size describe the input document
+	FileName  string
+	Size      int
+	Generated time.Time
+}
+
+// defaultTemplate is the page that the output is wrapped in without -template
+var defaultTemplate = template.Must(template.New("page").Parse(`<!doctype html>
+<html lang='en'>
+	<head>
+		<meta charset='utf-8'>
+		<title>{{.Title}}</title>
+		{{- if .StylesheetURL}}
+		<link rel='stylesheet' href='{{.StylesheetURL}}'>
+		{{- else}}
+		<style>
+{{.Stylesheet}}		</style>
+		{{- end}}
+		{{- if .Script}}
+		<script>
+{{.Script}}		</script>
+		{{- end}}
+	</head>
+	<body class="json" style="padding: 0; margin: 0">
+		<div style="padding: 5px">
+{{.Controls}}{{.Body}}
+		</div>
+	</body>
+</html>
+`))
+
+// loadTemplate turns the -template flag into a template
+func loadTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return defaultTemplate, nil
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(path).Parse(string(source))
+}
+
+// pageStylesheet returns the CSS that a page needs: the stylesheet of the
+// theme with -css, or else just the colours of the page and extraStylesheet.
+func pageStylesheet(options json.PrinterOptions, stylesheet string) string {
+	if options.CSSClasses {
+		return stylesheet
+	}
+	return fmt.Sprintf(".json { background-color: #%s; color: #%s; }\n", options.Theme.Background, options.Theme.Foreground) +
+		extraStylesheet(options)
+}
+
+// extraStylesheet returns the CSS that folds, line numbers and anchors need
+func extraStylesheet(options json.PrinterOptions) string {
+	css := ""
+	if options.Collapsible {
+		css += json.FoldStylesheet
+	}
+	if options.LineNumbers || options.PathAnchors {
+		css += json.AnchorStylesheet
+	}
+	return css
+}
+
+// pageScript returns the JavaScript that folds and anchors need
+func pageScript(options json.PrinterOptions) string {
+	script := ""
+	if options.Collapsible {
+		script += json.FoldScript
+	}
+	if options.PathAnchors {
+		script += json.AnchorScript
+	}
+	return script
+}
+
+// fragment wraps highlighted HTML in the <pre> block that -fragment writes.
+// Without -css the colours of the page are set inline, so that the block can
+// be pasted anywhere.
+func fragment(options json.PrinterOptions, highlighted string) string {
+	if options.CSSClasses {
+		return "<pre class='json'>" + highlighted + "</pre>"
+	}
+	return fmt.Sprintf("<pre class='json' style='background-color: #%s; color: #%s'>%s</pre>",
+		options.Theme.Background, options.Theme.Foreground, highlighted)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/scanner"
+	"time"
+
+	"./json"
+)
+
+// renderer prints documents, following the flags that the commands that print
+// documents share.
+type renderer struct {
+	options      json.PrinterOptions
+	format       string
+	color        string
+	theme        string
+	darkTheme    string
+	indent       string
+	cssOut       string
+	templatePath string
+	title        string
+	output       string
+	fragment     bool
+	highlight    bool
+
+	// stylesheet and template are worked out from the flags by setUp
+	stylesheet string
+	template   *template.Template
+}
+
+// addRenderFlags adds the flags of a renderer to flags, with format being the
+// output format to use by default.
+func addRenderFlags(flags *flag.FlagSet, format string) *renderer {
+	r := &renderer{options: json.DefaultPrinterOptions()}
+	options := &r.options
+	flags.StringVar(&r.output, "o", "", "write to this file instead of standard output")
+	flags.StringVar(&r.output, "output", "", "same as -o")
+	flags.StringVar(&r.format, "format", format, "output format: html, text or ansi")
+	flags.StringVar(&r.color, "color", "auto", "colours for the ansi format: auto, never, 16, 256 or truecolor")
+	flags.StringVar(&r.theme, "theme", options.Theme.Name, "colour theme: "+strings.Join(json.ThemeNames(), ", ")+", or a .json file")
+	flags.StringVar(&r.darkTheme, "dark-theme", "", "colour theme for readers who prefer a dark colour scheme, with -css")
+	flags.BoolVar(&options.CSSClasses, "css", options.CSSClasses, "mark tokens with CSS classes and a stylesheet, rather than inline colours")
+	flags.StringVar(&r.cssOut, "css-out", "", "write the stylesheet to this file and link to it, rather than embedding it; implies -css")
+	flags.BoolVar(&options.Collapsible, "collapsible", options.Collapsible, "let objects and arrays of the html format be folded by clicking on them")
+	flags.IntVar(&options.CollapseDepth, "collapse-depth", options.CollapseDepth, "start off folding the objects and arrays nested deeper than this, 0 for none; implies -collapsible")
+	flags.BoolVar(&options.LineNumbers, "line-numbers", options.LineNumbers, "number the lines of the html format")
+	flags.BoolVar(&options.PathAnchors, "anchors", options.PathAnchors, "give every property and element of the html format its JSON Pointer as an id, to link to")
+	flags.BoolVar(&r.fragment, "fragment", false, "write only the <pre> block of the html format, for embedding")
+	flags.StringVar(&r.templatePath, "template", "", "Go html/template file to write the html format with, instead of the default page")
+	flags.StringVar(&r.title, "title", "Here ye some JSON!", "title of the html page")
+	flags.StringVar(&r.indent, "indent", "2", "indentation: a number of spaces, or \"tab\"")
+	flags.BoolVar(&options.AlignKeys, "align-keys", options.AlignKeys, "line up the colons of an object")
+	flags.IntVar(&options.MaxKeyWidth, "max-key-width", options.MaxKeyWidth, "don't align objects with keys wider than this")
+	flags.IntVar(&options.InlineArrayMaxElements, "inline-array-max", options.InlineArrayMaxElements, "most scalars an array can have to be printed on one line")
+	flags.IntVar(&options.InlineArrayMaxWidth, "inline-array-width", options.InlineArrayMaxWidth, "widest an array can be to be printed on one line, 0 for no limit")
+	flags.BoolVar(&options.TrailingCommas, "trailing-commas", options.TrailingCommas, "put commas at the end of lines instead of the start")
+	return r
+}
+
+// setUp checks the flags, and works out the options, stylesheet and template
+// that they stand for.
+func (r *renderer) setUp() error {
+	options := &r.options
+	var err error
+	if options.Indent, err = parseIndent(r.indent); err != nil {
+		return err
+	}
+	switch r.format {
+	case "html":
+		options.Format = json.FormatHTML
+	case "text":
+		options.Format = json.FormatText
+	case "ansi":
+		options.Format = json.FormatANSI
+	default:
+		return fmt.Errorf("unknown format %q", r.format)
+	}
+	var out *os.File
+	if isStandardOutput(r.output) {
+		out = os.Stdout
+	}
+	colors, err := parseColorMode(r.color, out)
+	if err != nil {
+		return err
+	}
+	options.Colors = colors
+	if options.Theme, err = loadTheme(r.theme); err != nil {
+		return err
+	}
+
+	// Light and dark colour schemes need media queries, which only a stylesheet has
+	options.CSSClasses = options.CSSClasses || r.cssOut != "" || r.darkTheme != ""
+	r.stylesheet = json.Stylesheet(options.Theme)
+	if r.darkTheme != "" {
+		dark, err := loadTheme(r.darkTheme)
+		if err != nil {
+			return err
+		}
+		r.stylesheet = json.ColorSchemeStylesheet(options.Theme, dark)
+	}
+	options.Collapsible = options.Collapsible || options.CollapseDepth > 0
+	r.stylesheet += extraStylesheet(*options)
+
+	if options.Format != json.FormatHTML && (r.fragment || r.templatePath != "") {
+		return fmt.Errorf("-fragment and -template only work with the html format")
+	}
+	if r.template, err = loadTemplate(r.templatePath); err != nil {
+		return err
+	}
+	if r.cssOut != "" {
+		return os.WriteFile(r.cssOut, []byte(r.stylesheet), 0644)
+	}
+	return nil
+}
+
+// section is a printed document
+type section struct {
+	name   string
+	size   int
+	output string
+}
+
+// reportErrors writes every syntax error, with the line it is on, to w
+func reportErrors(w io.Writer, errs json.ErrorList) {
+	for _, syntaxError := range errs {
+		fmt.Fprintln(w, syntaxError)
+		if syntaxError.Line != "" {
+			fmt.Fprintln(w, syntaxError.Snippet())
+		}
+	}
+}
+
+// parseDocument parses source, reporting every problem with it to w rather than
+// just the first.
+func parseDocument(w io.Writer, name string, source []byte) (json.Node, json.ErrorList) {
+	var s scanner.Scanner
+	scanner := s.Init(bytes.NewReader(source))
+	scanner.Filename = name
+	tokenizer := json.NewTokenizer(scanner)
+	tree, errs := json.ParseTolerant(&tokenizer)
+	reportErrors(w, errs)
+	return tree, errs
+}
+
+// render parses and prints source, reporting its syntax errors to w. It returns
+// whether source is valid JSON. Only the html format shows invalid documents,
+// since the others are meant to be JSON; their sections are left empty.
+func (r *renderer) render(w io.Writer, name string, source []byte) (section, bool, error) {
+	tree, errs := parseDocument(w, name, source)
+	if len(errs) > 0 && r.options.Format != json.FormatHTML {
+		return section{name: name, size: len(source)}, false, nil
+	}
+	var output bytes.Buffer
+	printer := json.NewPrinter(&output, r.options)
+	var err error
+	if r.highlight {
+		err = printer.PrintHighlighted(string(source))
+	} else if len(errs) > 0 {
+		// Still show the document, so that it is easy to see where it breaks
+		err = printer.PrintError(string(source), errs[0])
+	} else {
+		err = printer.PrintTree(tree, 0)
+	}
+	return section{name, len(source), output.String()}, len(errs) == 0, err
+}
+
+// renderNode prints a node that was found in the document called name
+func (r *renderer) renderNode(name string, node json.Node) (section, error) {
+	var output bytes.Buffer
+	err := json.NewPrinter(&output, r.options).PrintTree(node, 0)
+	return section{name, output.Len(), output.String()}, err
+}
+
+// isStandardOutput reports whether path, from -o, stands for standard output
+func isStandardOutput(path string) bool {
+	return path == "" || path == "-"
+}
+
+// stylesheetURL returns the link to the stylesheet of -css-out, relative to
+// the page so that both can be moved together. A page on standard output is
+// taken to be in the working directory.
+func (r *renderer) stylesheetURL() string {
+	dir := "."
+	if !isStandardOutput(r.output) {
+		dir = filepath.Dir(r.output)
+	}
+	target := r.cssOut
+	page, pageErr := filepath.Abs(dir)
+	stylesheet, stylesheetErr := filepath.Abs(r.cssOut)
+	if pageErr == nil && stylesheetErr == nil {
+		if relative, err := filepath.Rel(page, stylesheet); err == nil {
+			target = relative
+		}
+	}
+	link := url.URL{Path: filepath.ToSlash(target)}
+	return link.String()
+}
+
+// openOutput opens the file of -o, or standard output without it
+func openOutput(path string) (io.WriteCloser, error) {
+	if isStandardOutput(path) {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// writeOutput writes the sections to the output
+func (r *renderer) writeOutput(sections []section) error {
+	w, err := openOutput(r.output)
+	if err != nil {
+		return err
+	}
+	if err := r.write(w, sections); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// write writes the sections to w: as text, under headings like those of head(1)
+// if there are several, or as a page or fragments of HTML.
+func (r *renderer) write(w io.Writer, sections []section) error {
+	var buffer bytes.Buffer
+	if r.options.Format != json.FormatHTML {
+		for i, section := range sections {
+			if len(sections) > 1 {
+				if i > 0 {
+					buffer.WriteString("\n")
+				}
+				fmt.Fprintf(&buffer, "==> %s <==\n", section.name)
+			}
+			buffer.WriteString(section.output)
+			if !strings.HasSuffix(section.output, "\n") {
+				buffer.WriteString("\n")
+			}
+		}
+		_, err := buffer.WriteTo(w)
+		return err
+	}
+
+	var bodies []string
+	var names []string
+	size := 0
+	for _, section := range sections {
+		body := fragment(r.options, section.output)
+		if len(sections) > 1 {
+			body = fmt.Sprintf("<section class='j-file'>\n<h2>%s</h2>\n%s\n</section>", html.EscapeString(section.name), body)
+		}
+		bodies = append(bodies, body)
+		names = append(names, section.name)
+		size += section.size
+	}
+
+	if r.fragment {
+		if (r.options.CSSClasses || extraStylesheet(r.options) != "") && r.cssOut == "" {
+			fmt.Fprintf(&buffer, "<style>\n%s</style>\n", pageStylesheet(r.options, r.stylesheet))
+		}
+		if script := pageScript(r.options); script != "" {
+			fmt.Fprintf(&buffer, "<script>\n%s</script>\n", script)
+		}
+		if r.options.Collapsible {
+			buffer.WriteString(json.FoldControls)
+		}
+		for _, body := range bodies {
+			buffer.WriteString(body)
+			buffer.WriteString("\n")
+		}
+		_, err := buffer.WriteTo(w)
+		return err
+	}
+
+	data := pageData{
+		Title:     r.title,
+		Body:      template.HTML(strings.Join(bodies, "\n")),
+		FileName:  strings.Join(names, ", "),
+		Size:      size,
+		Generated: time.Now(),
+	}
+	if r.cssOut != "" {
+		data.StylesheetURL = r.stylesheetURL()
+	} else {
+		data.Stylesheet = template.CSS(pageStylesheet(r.options, r.stylesheet))
+	}
+	data.Script = template.JS(pageScript(r.options))
+	if r.options.Collapsible {
+		data.Controls = template.HTML(json.FoldControls)
+	}
+	if err := r.template.Execute(&buffer, data); err != nil {
+		return err
+	}
+	_, err := buffer.WriteTo(w)
+	return err
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStylesheetURL(t *testing.T) {
+	tests := []struct {
+		output, cssOut, expected string
+	}{
+		{"", "style.css", "style.css"},
+		{"-", "css/style.css", "css/style.css"},
+		{"site/page.html", "site/style.css", "style.css"},
+		{"site/page.html", "style.css", "../style.css"},
+		{"page.html", "site/css/my style%.css", "site/css/my%20style%25.css"},
+		{"/srv/site/page.html", "/srv/css/style.css", "../css/style.css"},
+		{"page.html", "a:b.css", "./a:b.css"},
+	}
+	for _, test := range tests {
+		r := &renderer{output: test.output, cssOut: test.cssOut}
+		url := r.stylesheetURL()
+		assert(url == test.expected, fmt.Sprintf("Expected %s for %s next to %s, but got %s", test.expected, test.cssOut, test.output, url))
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"./json"
+)
+
+// maxDiffCells is the most pairs of lines that diffLines compares, which bounds
+// the time it takes. Longer runs of changed lines are shown as removed and then
+// added as a whole.
+const maxDiffCells = 1 << 24
+
+// edit is a line of a diff, with op being ' ' for a line that both sides
+// have, '-' for a removed line and '+' for an added one.
+type edit struct {
+	op   byte
+	line string
+}
+
+// diffLines returns the edits that turn the lines before into after, keeping
+// as many lines as it can.
+func diffLines(before, after []string) []edit {
+	var edits []edit
+	a, b := before, after
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		edits = append(edits, edit{' ', a[prefix]})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	if len(a)*len(b) > maxDiffCells {
+		edits = replaceLines(edits, a, b)
+	} else {
+		edits = commonEdits(edits, a, b)
+	}
+
+	for _, line := range before[len(before)-suffix:] {
+		edits = append(edits, edit{' ', line})
+	}
+	return edits
+}
+
+// replaceLines appends the edits that remove every line of a, and then add
+// every line of b
+func replaceLines(edits []edit, a, b []string) []edit {
+	for _, line := range a {
+		edits = append(edits, edit{'-', line})
+	}
+	for _, line := range b {
+		edits = append(edits, edit{'+', line})
+	}
+	return edits
+}
+
+// commonEdits appends the edits that turn a into b keeping their longest
+// common subsequence, found with Hirschberg's algorithm: a is split in half,
+// and b where the lengths of the common subsequences on either side add up the
+// most. Both sides are then diffed in turn, so that only a row of lengths is
+// ever kept rather than the whole table.
+func commonEdits(edits []edit, a, b []string) []edit {
+	switch {
+	case len(a) == 0 || len(b) == 0:
+		return replaceLines(edits, a, b)
+	case len(a) == 1:
+		for j, line := range b {
+			if line == a[0] {
+				edits = replaceLines(edits, nil, b[:j])
+				edits = append(edits, edit{' ', line})
+				return replaceLines(edits, nil, b[j+1:])
+			}
+		}
+		return replaceLines(edits, a, b)
+	}
+
+	middle := len(a) / 2
+	forward := commonLengths(a[:middle], b)
+	backward := commonLengths(reversed(a[middle:]), reversed(b))
+	// The first best split keeps removed lines ahead of added ones
+	split := 0
+	for j := range forward {
+		if forward[j]+backward[len(b)-j] > forward[split]+backward[len(b)-split] {
+			split = j
+		}
+	}
+	edits = commonEdits(edits, a[:middle], b[:split])
+	return commonEdits(edits, a[middle:], b[split:])
+}
+
+// commonLengths returns the lengths of the longest common subsequences of a
+// and b[:j], for every j
+func commonLengths(a, b []string) []int32 {
+	row := make([]int32, len(b)+1)
+	for _, line := range a {
+		// diagonal is the length for the line before and b[:j-1]
+		diagonal := int32(0)
+		for j := 1; j <= len(b); j++ {
+			above := row[j]
+			if line == b[j-1] {
+				row[j] = diagonal + 1
+			} else if row[j-1] > above {
+				row[j] = row[j-1]
+			}
+			diagonal = above
+		}
+	}
+	return row
+}
+
+// reversed returns a copy of lines in reverse order
+func reversed(lines []string) []string {
+	reversed := make([]string, len(lines))
+	for i, line := range lines {
+		reversed[len(lines)-1-i] = line
+	}
+	return reversed
+}
+
+// splitLines splits text into lines, each keeping its newline
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// hunkRange writes the start and length of a hunk on one side, after the line
+// before, as diff -u does: an empty hunk starts at the line before it, and the
+// length of a single line is left out.
+func hunkRange(before, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, length)
+}
+
+// unifiedDiff returns the changes from before to after as a unified diff, with
+// three lines of context, like diff -u.
+func unifiedDiff(name, before, after string) string {
+	const context = 3
+	edits := diffLines(splitLines(before), splitLines(after))
+	var changes []int
+	for i, edit := range edits {
+		if edit.op != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "--- %s.orig\n+++ %s\n", name, name)
+	for first := 0; first < len(changes); {
+		// Changes that are close enough to share their context make up a hunk
+		last := first
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*context+1 {
+			last++
+		}
+		start, end := changes[first]-context, changes[last]+context+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		oldBefore, newBefore := 0, 0
+		for _, edit := range edits[:start] {
+			if edit.op != '+' {
+				oldBefore++
+			}
+			if edit.op != '-' {
+				newBefore++
+			}
+		}
+		oldLength, newLength := 0, 0
+		for _, edit := range edits[start:end] {
+			if edit.op != '+' {
+				oldLength++
+			}
+			if edit.op != '-' {
+				newLength++
+			}
+		}
+		fmt.Fprintf(&buffer, "@@ -%s +%s @@\n", hunkRange(oldBefore, oldLength), hunkRange(newBefore, newLength))
+		for _, edit := range edits[start:end] {
+			buffer.WriteByte(edit.op)
+			buffer.WriteString(edit.line)
+			if !strings.HasSuffix(edit.line, "\n") {
+				buffer.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		first = last + 1
+	}
+	return buffer.String()
+}
+
+// writeFileAtomic replaces the file at path with data, keeping its permissions.
+// The data is written to a temporary file next to it first, and renamed over
+// it, so that the file is never left half written. A symbolic link is left as
+// it is, and the file it points to is replaced.
+func writeFileAtomic(path string, data []byte) error {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	// Once renamed, there is nothing left to remove
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Chmod(info.Mode().Perm()); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// rewriteFlags are the flags of format that check or rewrite files, like
+// those of gofmt
+type rewriteFlags struct {
+	write, check, diff bool
+}
+
+func (f rewriteFlags) any() bool {
+	return f.write || f.check || f.diff
+}
+
+// runRewrite lays out every document as text, and lists, diffs or rewrites the
+// ones that were not laid out that way already, as flags say. Invalid documents
+// are left alone.
+func runRewrite(r *renderer, paths []string, jobs int, walked bool, flags rewriteFlags) int {
+	// The output is opened first, so that nothing is rewritten if it can not be
+	w, err := openOutput(r.output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitTrouble
+	}
+
+	// The names and diffs of the files are written in order, once all are done
+	reports := make([]string, len(paths))
+	outcomes := process(paths, jobs, func(i int, path string, o *outcome) {
+		if path == "-" && flags.write {
+			fmt.Fprintln(&o.problems, "-w can not rewrite standard input")
+			o.status = exitTrouble
+			return
+		}
+		name, source, err := readInput(path)
+		if err != nil {
+			fmt.Fprintln(&o.problems, err)
+			o.status = exitTrouble
+			return
+		}
+		section, valid, err := r.render(&o.problems, name, source)
+		if err != nil {
+			fmt.Fprintf(&o.problems, "%s: %v\n", name, err)
+			o.status = exitTrouble
+			return
+		}
+		if !valid {
+			o.status = exitInvalid
+			return
+		}
+		formatted := section.output + "\n"
+		if formatted == string(source) {
+			return
+		}
+		// Whatever the options, a file is never replaced with something broken
+		if diagnostics := json.Validate(strings.NewReader(formatted)); len(diagnostics) > 0 {
+			fmt.Fprintf(&o.problems, "%s: laid out, it would no longer be valid: %s\n", name, diagnostics[0])
+			o.status = exitTrouble
+			return
+		}
+
+		o.changed = true
+		if flags.check {
+			reports[i] += name + "\n"
+			o.status = exitInvalid
+		}
+		if flags.diff {
+			reports[i] += unifiedDiff(name, string(source), formatted)
+		}
+		if flags.write {
+			if err := writeFileAtomic(path, []byte(formatted)); err != nil {
+				fmt.Fprintln(&o.problems, err)
+				o.status = exitTrouble
+				o.changed = false
+			}
+		}
+	})
+	var buffer bytes.Buffer
+	for _, report := range reports {
+		buffer.WriteString(report)
+	}
+	_, err = buffer.WriteTo(w)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitTrouble
+	}
+	return summarize(outcomes, walked, true)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func assert(b bool, s string) {
+	if !b {
+		panic(s)
+	}
+}
+
+// numbered returns the lines 1 to n, with some of them replaced
+func numbered(n int, replaced map[int]string) string {
+	var lines []string
+	for i := 1; i <= n; i++ {
+		if line, ok := replaced[i]; ok {
+			lines = append(lines, line)
+		} else {
+			lines = append(lines, strconv.Itoa(i))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name, before, after, expected string
+	}{
+		{"same", "a\n", "a\n", ""},
+		{"added to empty", "", "a\n", "@@ -0,0 +1 @@\n+a\n"},
+		{"all removed", "a\n", "", "@@ -1 +0,0 @@\n-a\n"},
+		{"newline added", "a", "a\n", "@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n"},
+		{"newline removed", "a\n", "a", "@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n"},
+		{
+			"context", numbered(10, nil), numbered(10, map[int]string{5: "five"}),
+			"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			"two hunks", numbered(20, nil), numbered(20, map[int]string{3: "x", 17: "y"}),
+			"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+x\n 4\n 5\n 6\n" +
+				"@@ -14,7 +14,7 @@\n 14\n 15\n 16\n-17\n+y\n 18\n 19\n 20\n",
+		},
+		{
+			"merged hunks", numbered(20, nil), numbered(20, map[int]string{3: "x", 9: "y"}),
+			"@@ -1,12 +1,12 @@\n 1\n 2\n-3\n+x\n 4\n 5\n 6\n 7\n 8\n-9\n+y\n 10\n 11\n 12\n",
+		},
+		{
+			"inserted", "a\nb\n", "a\nx\nb\n",
+			"@@ -1,2 +1,3 @@\n a\n+x\n b\n",
+		},
+	}
+	for _, test := range tests {
+		expected := test.expected
+		if expected != "" {
+			expected = "--- f.json.orig\n+++ f.json\n" + expected
+		}
+		diff := unifiedDiff("f.json", test.before, test.after)
+		assert(diff == expected, fmt.Sprintf("%s: expected\n%s\nbut got\n%s", test.name, expected, diff))
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	var ops []string
+	for _, edit := range diffLines([]string{"a", "b", "c", "d"}, []string{"a", "c", "x", "d", "e"}) {
+		ops = append(ops, string(edit.op)+edit.line)
+	}
+	assert(strings.Join(ops, " ") == " a -b  c +x  d +e", fmt.Sprintf("Wrong edits %q", ops))
+
+	ops = nil
+	for _, edit := range diffLines([]string{"a", "b", "c"}, []string{"x", "y"}) {
+		ops = append(ops, string(edit.op)+edit.line)
+	}
+	assert(strings.Join(ops, " ") == "-a -b -c +x +y", fmt.Sprintf("Should have removed the lines before adding, not %q", ops))
+
+	// Lines changed throughout a long file are each replaced on their own
+	replaced := map[int]string{}
+	for i := 1; i <= 3000; i += 3 {
+		replaced[i] = "x"
+	}
+	removed, added := 0, 0
+	for _, edit := range diffLines(splitLines(numbered(3000, nil)), splitLines(numbered(3000, replaced))) {
+		switch edit.op {
+		case '-':
+			removed++
+		case '+':
+			added++
+		}
+	}
+	assert(removed == len(replaced) && added == len(replaced), fmt.Sprintf("Should have replaced %d lines, not removed %d and added %d", len(replaced), removed, added))
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.json")
+	assert(os.WriteFile(path, []byte("old"), 0640) == nil, "Should have written the file")
+	assert(os.Chmod(path, 0640) == nil, "Should have set the permissions")
+
+	assert(writeFileAtomic(path, []byte("new")) == nil, "Should have rewritten the file")
+	data, _ := os.ReadFile(path)
+	assert(string(data) == "new", fmt.Sprintf("Wrong contents %q", data))
+	info, _ := os.Stat(path)
+	assert(info.Mode().Perm() == 0640, fmt.Sprintf("Should have kept the permissions, not %v", info.Mode()))
+	entries, _ := os.ReadDir(dir)
+	assert(len(entries) == 1, fmt.Sprintf("Should not have left a temporary file, but found %d files", len(entries)))
+
+	// A symbolic link stays one, and the file it points to is rewritten
+	link := filepath.Join(dir, "link.json")
+	assert(os.Symlink("a.json", link) == nil, "Should have made the link")
+	assert(writeFileAtomic(link, []byte("linked")) == nil, "Should have rewritten through the link")
+	info, _ = os.Lstat(link)
+	assert(info.Mode()&os.ModeSymlink != 0, "Should have kept the link")
+	data, _ = os.ReadFile(path)
+	assert(string(data) == "linked", fmt.Sprintf("Should have rewritten the target, not left %q", data))
+
+	assert(writeFileAtomic(filepath.Join(dir, "missing.json"), []byte("x")) != nil, "Should not have created a missing file")
+}